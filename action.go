@@ -0,0 +1,184 @@
+package resweave
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ActionScope determines whether a CustomAction applies to a resource collection or to a single instance.
+type ActionScope int
+
+const (
+	// InstanceScope actions are served at /<resource>/<id>:<name> and /<resource>/<id>/actions/<name>.
+	InstanceScope ActionScope = iota
+	// CollectionScope actions are served at /<resource>/actions/<name>.
+	CollectionScope
+)
+
+const (
+	// customActionBase is the first ActionType assigned to a custom action.
+	customActionBase ActionType = 1000
+	actionsSegment              = "actions"
+	actionSeparator             = ":"
+	fmtInvalidAction            = "%w: %s"
+)
+
+// CustomAction is an RPC style action on an APIResource beyond the CRUD actions, e.g. POST /orders/{id}:cancel.
+type CustomAction struct {
+	// Name of the action as it appears in the path.
+	Name string
+	// Method is the HTTP method the action is served for, e.g. http.MethodPost.
+	Method string
+	// Scope determines whether the action applies to the collection or to an instance.
+	Scope ActionScope
+	// Handler handles the action.
+	Handler ResweaveFunc
+}
+
+// registeredAction is a CustomAction added to a resource, with the ActionType assigned to it by AddAction.
+type registeredAction struct {
+	CustomAction
+	actionType ActionType
+}
+
+// IsCustom returns true if the ActionType was assigned to a CustomAction.
+func (at ActionType) IsCustom() bool {
+	return at >= customActionBase
+}
+
+// validate checks the action can be added to a resource.
+func (ca CustomAction) validate() error {
+	switch {
+	case ca.Name == "" || strings.ContainsAny(ca.Name, "/"+actionSeparator):
+		return fmt.Errorf(fmtInvalidAction, ErrInvalidAction, "name must be a non-empty path segment without ':'")
+	case ca.Method == "":
+		return fmt.Errorf(fmtInvalidAction, ErrInvalidAction, "method is required")
+	case ca.Scope != InstanceScope && ca.Scope != CollectionScope:
+		return fmt.Errorf(fmtInvalidAction, ErrInvalidAction, "unknown scope")
+	case ca.Handler == nil:
+		return fmt.Errorf(fmtInvalidAction, ErrInvalidAction, "handler is required")
+	}
+	return nil
+}
+
+func (bar *BaseAPIRes) AddAction(ca CustomAction) (ActionType, error) {
+	if err := ca.validate(); err != nil {
+		bar.Infow("AddAction", "Name", ca.Name, "Error", err)
+		return unknown, err
+	}
+	var at ActionType
+	err := bar.customActions.update(func(actions map[string]registeredAction) error {
+		if _, found := actions[ca.Name]; found {
+			return fmt.Errorf("%w: '%s' in '%s'", ErrActionAlreadyExists, ca.Name, bar.Name())
+		}
+		// Actions are never removed, so the next ActionType is always unused.
+		at = customActionBase + ActionType(len(actions))
+		actions[ca.Name] = registeredAction{CustomAction: ca, actionType: at}
+		return nil
+	})
+	if err != nil {
+		bar.Infow("AddAction", "Name", ca.Name, "Error", err)
+		return unknown, err
+	}
+	bar.setFunction(at, ca.Handler)
+	bar.Infow("AddAction", "Name", ca.Name, "Action", at, "Added", true)
+	return at, nil
+}
+
+func (bar *BaseAPIRes) CustomActions() []CustomAction {
+	actions := make([]CustomAction, 0, bar.customActions.len())
+	for _, ra := range bar.customActions.load() {
+		actions = append(actions, ra.CustomAction)
+	}
+	slices.SortFunc(actions, func(a, b CustomAction) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return actions
+}
+
+func (bar *BaseAPIRes) CustomActionFor(at ActionType) (CustomAction, bool) {
+	for _, ra := range bar.customActions.load() {
+		if ra.actionType == at {
+			return ra.CustomAction, true
+		}
+	}
+	return CustomAction{}, false
+}
+
+// customActionFromPath finds a custom action addressed by the path segments following the resource name:
+// actions/<name> for collection actions, or <id>:<name> for instance actions, in which case the action name is
+// stripped from the ID segment in the returned context.
+// Instance actions addressed as <id>/actions/<name> are found once the ID has been stored; see instanceAction.
+func (bar *BaseAPIRes) customActionFromPath(ctx context.Context) (context.Context, registeredAction, bool) {
+	segments, _ := ctx.Value(KeyURISegments).([]ResourceName)
+	idx := slices.Index(segments, bar.Name())
+	if idx < 0 {
+		return ctx, registeredAction{}, false
+	}
+	rest := segments[idx+1:]
+	if len(rest) > 0 && rest[len(rest)-1] == "" {
+		rest = rest[:len(rest)-1]
+	}
+	switch {
+	case len(rest) == 2 && rest[0] == actionsSegment:
+		if ca, found := bar.customActions.get(rest[1].String()); found && ca.Scope == CollectionScope {
+			return ctx, ca, true
+		}
+	case len(rest) == 1:
+		id, name, found := strings.Cut(rest[0].String(), actionSeparator)
+		if !found {
+			break
+		}
+		if ca, found := bar.customActions.get(name); found && ca.Scope == InstanceScope {
+			segments = slices.Clone(segments)
+			segments[idx+1] = ResourceName(id)
+			return context.WithValue(ctx, KeyURISegments, segments), ca, true
+		}
+	}
+	return ctx, registeredAction{}, false
+}
+
+// instanceAction finds an instance action addressed as actions/<name> in the segments remaining after the ID.
+func (bar *BaseAPIRes) instanceAction(ctx context.Context, segments []ResourceName) (registeredAction, bool) {
+	if len(segments) != 2 || segments[0] != actionsSegment || !bar.hasID(ctx) {
+		return registeredAction{}, false
+	}
+	ca, found := bar.customActions.get(segments[1].String())
+	return ca, found && ca.Scope == InstanceScope
+}
+
+// methods returns the HTTP methods the action is served for, including HEAD for GET actions and OPTIONS, which are
+// answered automatically.
+func (ca CustomAction) methods() []string {
+	methods := []string{ca.Method, http.MethodOptions}
+	if ca.Method == http.MethodGet {
+		methods = append(methods, http.MethodHead)
+	}
+	slices.Sort(methods)
+	return slices.Compact(methods)
+}
+
+// serveCustomAction runs the custom action if the request method matches, answering OPTIONS and HEAD (for GET
+// actions) automatically and anything else with a 405.
+func (bar *BaseAPIRes) serveCustomAction(ctx context.Context, w http.ResponseWriter, req *http.Request, ca registeredAction) {
+	at := ca.actionType
+	switch {
+	case req.Method == ca.Method:
+		bar.idempotent(ctx, w, req, func(w http.ResponseWriter, req *http.Request) {
+			bar.getHandler()(at, ctx, w, req)
+		})
+	case req.Method == http.MethodHead && ca.Method == http.MethodGet:
+		hw := newHeadResponseWriter(w)
+		bar.getHandler()(at, ctx, hw, req)
+		hw.finish()
+	case req.Method == http.MethodOptions:
+		w.Header().Set(headerAllow, strings.Join(ca.methods(), ", "))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set(headerAllow, strings.Join(ca.methods(), ", "))
+		methodNotAllowed(ctx, w, req)
+	}
+}
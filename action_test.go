@@ -0,0 +1,146 @@
+package resweave_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/mortedecai/resweave"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Custom Actions", func() {
+	var (
+		s      resweave.Server
+		orders resweave.APIResource
+		called string
+		id     string
+	)
+	// action returns a ResweaveFunc recording the action name and order ID.
+	action := func(name string) resweave.ResweaveFunc {
+		return func(ctx context.Context, w http.ResponseWriter, _ *http.Request) {
+			called = name
+			id, _ = orders.GetIDValue(ctx)
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}
+	do := func(method string, target string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+		return recorder
+	}
+
+	BeforeEach(func() {
+		called, id = "", ""
+		s = resweave.NewServer(0)
+		orders = resweave.NewAPI("orders")
+		orders.SetFetch(action("fetch"))
+		_, err := orders.AddAction(resweave.CustomAction{Name: "cancel", Method: http.MethodPost, Handler: action("cancel")})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = orders.AddAction(resweave.CustomAction{
+			Name: "archive", Method: http.MethodPost, Scope: resweave.CollectionScope, Handler: action("archive"),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s.AddResource(orders)).To(Succeed())
+	})
+
+	It("should serve instance actions with the colon and actions path forms", func() {
+		Expect(do(http.MethodPost, "/orders/42:cancel").Code).To(Equal(http.StatusAccepted))
+		Expect(called).To(Equal("cancel"))
+		Expect(id).To(Equal("42"))
+
+		called, id = "", ""
+		Expect(do(http.MethodPost, "/orders/7/actions/cancel").Code).To(Equal(http.StatusAccepted))
+		Expect(called).To(Equal("cancel"))
+		Expect(id).To(Equal("7"))
+	})
+
+	It("should serve collection actions", func() {
+		Expect(do(http.MethodPost, "/orders/actions/archive").Code).To(Equal(http.StatusAccepted))
+		Expect(called).To(Equal("archive"))
+		Expect(id).To(BeEmpty())
+	})
+
+	It("should not serve actions in the wrong scope or for unknown actions", func() {
+		Expect(do(http.MethodPost, "/orders/actions/cancel").Code).To(Equal(http.StatusNotFound))
+		// Not an instance action, so routed as a POST to order 42, which has no Create handler.
+		Expect(do(http.MethodPost, "/orders/42:archive").Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(do(http.MethodPost, "/orders/42/actions/refund").Code).To(Equal(http.StatusNotFound))
+		Expect(called).To(BeEmpty())
+	})
+
+	It("should answer other methods with a 405 and OPTIONS with the allowed methods", func() {
+		rec := do(http.MethodGet, "/orders/42:cancel")
+		Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(rec.Header().Get("Allow")).To(Equal("OPTIONS, POST"))
+
+		rec = do(http.MethodOptions, "/orders/42/actions/cancel")
+		Expect(rec.Code).To(Equal(http.StatusNoContent))
+		Expect(rec.Header().Get("Allow")).To(Equal("OPTIONS, POST"))
+		Expect(called).To(BeEmpty())
+	})
+
+	It("should pass the action's ActionType to a custom HandlerFunction", func() {
+		var got resweave.ActionType
+		orders.SetHandler(func(at resweave.ActionType, _ context.Context, w http.ResponseWriter, _ *http.Request) {
+			got = at
+		})
+		do(http.MethodPost, "/orders/42:cancel")
+		Expect(got.IsCustom()).To(BeTrue())
+		ca, found := orders.CustomActionFor(got)
+		Expect(found).To(BeTrue())
+		Expect(ca.Name).To(Equal("cancel"))
+	})
+
+	It("should reject invalid and duplicate actions", func() {
+		ok := action("ok")
+		for _, ca := range []resweave.CustomAction{
+			{Method: http.MethodPost, Handler: ok},
+			{Name: "a:b", Method: http.MethodPost, Handler: ok},
+			{Name: "a/b", Method: http.MethodPost, Handler: ok},
+			{Name: "ship"},
+			{Name: "ship", Method: http.MethodPost},
+			{Name: "ship", Method: http.MethodPost, Scope: resweave.ActionScope(5), Handler: ok},
+		} {
+			_, err := orders.AddAction(ca)
+			Expect(err).To(MatchError(resweave.ErrInvalidAction))
+		}
+		_, err := orders.AddAction(resweave.CustomAction{Name: "cancel", Method: http.MethodPost, Handler: ok})
+		Expect(err).To(MatchError(resweave.ErrActionAlreadyExists))
+	})
+
+	It("should assign ActionTypes per resource and name any ActionType", func() {
+		invoices := resweave.NewAPI("invoices")
+		_, err := invoices.AddAction(resweave.CustomAction{Name: "a:b", Method: http.MethodPost, Handler: action("x")})
+		Expect(err).To(MatchError(resweave.ErrInvalidAction))
+		void, err := invoices.AddAction(resweave.CustomAction{Name: "void", Method: http.MethodPost, Handler: action("void")})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = invoices.AddAction(resweave.CustomAction{Name: "void", Method: http.MethodPost, Handler: action("x")})
+		Expect(err).To(MatchError(resweave.ErrActionAlreadyExists))
+		cancel, err := invoices.AddAction(resweave.CustomAction{Name: "cancel", Method: http.MethodPost, Handler: action("x")})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(invoices.Actions()).To(Equal([]resweave.ActionType{void, cancel}))
+		Expect(orders.Actions()).To(ContainElement(void))
+		ca, _ := orders.CustomActionFor(void)
+		Expect(ca.Name).To(Equal("cancel"))
+		_, found := invoices.CustomActionFor(resweave.Delete)
+		Expect(found).To(BeFalse())
+		Expect(resweave.Delete.String()).To(Equal("Delete"))
+		Expect(resweave.ActionType(-1).String()).To(Equal("ActionType(-1)"))
+		Expect(resweave.ActionType(999).String()).To(Equal("ActionType(999)"))
+	})
+
+	It("should show up in the routes", func() {
+		rt := s.Routes()
+		Expect(rt).To(HaveLen(4))
+		Expect(rt[1].Path).To(Equal("/orders/actions/archive"))
+		Expect(rt[1].Methods).To(Equal([]string{http.MethodOptions, http.MethodPost}))
+		Expect(rt[3].Path).To(Equal("/orders/{orders_id}:cancel"))
+		ca, _ := orders.CustomActionFor(rt[3].Actions[0])
+		Expect(ca.Name).To(Equal("cancel"))
+		Expect(rt[3].Params).To(Equal([]resweave.RouteParam{{Name: "orders_id", ID: resweave.NumericID}}))
+		Expect(rt.String()).To(MatchRegexp(`/orders/\{orders_id\}:cancel\s+API\s+OPTIONS,POST\s+cancel`))
+	})
+})
@@ -4,8 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
 	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
 )
 
 // Key is a key type for looking up a resweave data in the context
@@ -20,9 +24,6 @@ type ActionType int
 // HandlerFunction is a type alias for the request handler
 type HandlerFunction func(ActionType, context.Context, http.ResponseWriter, *http.Request)
 
-// actionFuncMap is a type alias for a map of actionTypes to ResweaveFuncs
-type actionFuncMap map[ActionType]ResweaveFunc
-
 const (
 	// NumericID is a default representation for a numeric identifier.
 	NumericID ID = ID("([0-9]+)")
@@ -33,6 +34,8 @@ const (
 	KeyRequestID                      = Key("INCOMING_REQUEST_ID")
 	fmtResourceAlreadyExists          = "%w: '%s' in '%s'"
 	fmtInstancedResourceAlreadyExists = "%w: '<id>/%s' in '%s'"
+	fmtResourceNotFound               = "%w: '%s' in '%s'"
+	fmtInstancedResourceNotFound      = "%w: '<id>/%s' in '%s'"
 )
 
 const (
@@ -42,6 +45,9 @@ const (
 	Fetch
 	Update
 	Delete
+	Head
+	Options
+	Patch
 )
 
 var (
@@ -49,10 +55,19 @@ var (
 	ErrNilResource                = errors.New("cannot add a nil resource")
 	ErrResourceAlreadyExists      = errors.New("sub-resource already exists")
 	ErrChildResourceAlreadyExists = errors.New("child already exists")
+	ErrResourceNotFound           = errors.New("resource not found")
+	ErrChildResourceNotFound      = errors.New("child not found")
+	ErrInvalidAction              = errors.New("invalid custom action")
+	ErrActionAlreadyExists        = errors.New("custom action already exists")
 )
 
+var actionNames = [...]string{"Unknown", "Create", "List", "Fetch", "Update", "Delete", "Head", "Options", "Patch"}
+
 func (at ActionType) String() string {
-	return [...]string{"Unknown", "Create", "List", "Fetch", "Update", "Delete"}[at]
+	if at >= 0 && int(at) < len(actionNames) {
+		return actionNames[at]
+	}
+	return fmt.Sprintf("ActionType(%d)", int(at))
 }
 
 // ID.IsValid returns true if the represented ID is valid regeix, or false and the error otherwise.
@@ -93,8 +108,54 @@ type APIResource interface {
 	SetDelete(f ResweaveFunc)
 	// SetUpdate sets the function to use for handling incoming update requests.
 	SetUpdate(f ResweaveFunc)
+	// SetPatch sets the function to use for handling incoming PATCH requests.
+	// If no function is set, PATCH requests are handled as Update.
+	SetPatch(f ResweaveFunc)
+	// SetPatchMediaTypes sets the media types accepted for PATCH requests, e.g. "application/merge-patch+json".
+	// They are advertised in the Accept-Patch header of OPTIONS responses, and PATCH requests with any other
+	// Content-Type receive a 415. With no media types, any Content-Type is accepted.
+	SetPatchMediaTypes(mediaTypes ...string)
+	// SetHead sets the function to use for handling incoming HEAD requests.
+	// If no function is set, the List or Fetch function is run with its body discarded.
+	SetHead(f ResweaveFunc)
+	// SetOptions sets the function to use for handling incoming OPTIONS requests.
+	// If no function is set, OPTIONS requests are answered with a 204 and an Allow header.
+	SetOptions(f ResweaveFunc)
+	// AddAction adds a custom action, served at /<resource>/actions/<name> for CollectionScope actions and at
+	// /<resource>/<id>:<name> or /<resource>/<id>/actions/<name> for InstanceScope actions, returning the ActionType
+	// passed to the HandlerFunction for it. The ActionType is specific to the resource; see CustomActionFor.
+	// If the action is invalid an error wrapping ErrInvalidAction is returned, and if an action with the same name
+	// exists on the resource an error wrapping ErrActionAlreadyExists.
+	AddAction(a CustomAction) (ActionType, error)
+	// CustomActions returns the custom actions added to the resource, sorted by name.
+	CustomActions() []CustomAction
+	// CustomActionFor returns the custom action of the resource with the ActionType returned by AddAction.
+	CustomActionFor(at ActionType) (CustomAction, bool)
+	// SetListOptions enables the ListQuery for List requests, declaring the limits and the fields which may be sorted
+	// and filtered on. List requests with an invalid query receive a 400; see ListQueryFromContext.
+	// Negative limits, or a DefaultLimit above a MaxLimit, return an error wrapping ErrInvalidListOptions.
+	SetListOptions(opts ListOptions) error
+	// SetVersion sets the function returning the current Version at a path, enabling conditional requests:
+	// If-None-Match and If-Modified-Since on List and Fetch are answered with a 304 when unchanged, and If-Match and
+	// If-Unmodified-Since on Update, Patch and Delete with a 412 when changed. List and Fetch responses have the
+	// ETag and Last-Modified headers set. nil disables conditional requests.
+	SetVersion(f VersionFunc)
+	// SetRequirePreconditions requires Update, Patch and Delete requests to have an If-Match or If-Unmodified-Since
+	// header, answering those without with a 428. It has no effect unless a VersionFunc is set.
+	SetRequirePreconditions(required bool)
+	// SetIdempotency enables the Idempotency-Key header for Create and custom action requests, other than those with a
+	// safe method such as GET. The response to a request with the header is stored, and retries with the same key
+	// receive it again with the Idempotent-Replayed header set. A retry while the request is in progress receives a 409,
+	// and reusing the key for a different request a 422. Responses with a 5xx status are not stored. nil disables it.
+	// Keys are shared by every caller unless scoped with WithIdempotencyScope, and bodies larger than the limit set with
+	// WithIdempotencyMaxBody receive a 413.
+	SetIdempotency(store IdempotencyStore, opts ...IdempotencyOption)
 	// SetID sets the regex for validating / parsing IDs for this resource.
 	SetID(id ID) error
+	// GetID returns the regex for validating / parsing IDs for this resource.
+	GetID() ID
+	// Actions returns the actions which have a handler function set, including custom actions, in ActionType order.
+	Actions() []ActionType
 	// SetHandler sets the handler function for this resource.
 	SetHandler(handler HandlerFunction)
 	// GetIDValue retrieves the ID value from the provided context for this call.
@@ -112,31 +173,71 @@ type APIResource interface {
 	//     * `/users/<id>/emails` could allow the creation, viewing or editing of a particular users email(s).
 	//     * `/users/<id>/emails/foo%40bar.com` could allow the deletion of a particular users `foo@bar.com` e-mail.
 	AddChildResource(Resource) error
+	// Resources returns the sub-resources added via AddResource, sorted by name.
+	Resources() []Resource
+	// ChildResources returns the child resources added via AddChildResource, sorted by name.
+	ChildResources() []Resource
+	// RemoveResource removes the sub-resource with the provided name, returning the removed resource.
+	// If no sub-resource with the name exists, an error wrapping ErrResourceNotFound is returned.
+	RemoveResource(name ResourceName) (Resource, error)
+	// ReplaceResource replaces the sub-resource with the same name as r, returning the replaced resource.
+	// If no sub-resource with the name exists, an error wrapping ErrResourceNotFound is returned.
+	ReplaceResource(r Resource) (Resource, error)
+	// RemoveChildResource removes the child resource with the provided name, returning the removed resource.
+	// If no child resource with the name exists, an error wrapping ErrChildResourceNotFound is returned.
+	RemoveChildResource(name ResourceName) (Resource, error)
+	// ReplaceChildResource replaces the child resource with the same name as r, returning the replaced resource.
+	// If no child resource with the name exists, an error wrapping ErrChildResourceNotFound is returned.
+	ReplaceChildResource(r Resource) (Resource, error)
+	// SetNotFoundHandler sets the function responding to requests for unknown sub-resources of this resource and
+	// its sub-resources, overriding any set on the Server or Host. nil restores the inherited handler.
+	SetNotFoundHandler(f ResweaveFunc)
+	// SetMethodNotAllowedHandler sets the function responding to requests for actions without a handler on this
+	// resource and its sub-resources, overriding any set on the Server or Host. The Allow header listing the methods
+	// with handlers set has already been set when it is called. nil restores the inherited handler.
+	SetMethodNotAllowedHandler(f ResweaveFunc)
+	// AddInterceptor adds an interceptor to the start of the resource's handling chain, as for Server.AddInterceptor.
+	// Resource interceptors run after those of the Server, Host and any parent resources, and apply to the resource's
+	// sub-resources and child resources as well. The resource is available to the interceptor via ResourceFromContext.
+	AddInterceptor(f Interceptor)
+	// AddShutdownHook registers a hook to be run when the Server shuts down.
+	// Hooks on a resource are run after the shutdown hooks of its sub-resources and child resources.
+	AddShutdownHook(hook ShutdownHook)
+	Shutdowner
 }
 
 // BaseAPIRes supplies the basic building blocks for an APIResource.
 // It may be used through composition
 type BaseAPIRes struct {
 	LogHolder
-	name           ResourceName
-	actionMap      actionFuncMap
-	id             ID
-	handler        HandlerFunction
-	resources      ResourceMap
-	childResources ResourceMap
+	name                 ResourceName
+	actionMap            routeTable[ActionType, ResweaveFunc]
+	customActions        routeTable[string, registeredAction]
+	id                   atomic.Pointer[ID]
+	patchTypes           atomic.Pointer[[]string]
+	noPatchFallback      atomic.Bool
+	listOptions          atomic.Pointer[ListOptions]
+	version              atomic.Pointer[VersionFunc]
+	requirePreconditions atomic.Bool
+	idempotency          atomic.Pointer[idempotencySettings]
+	handler              atomic.Pointer[HandlerFunction]
+	resources            routeTable[ResourceName, Resource]
+	childResources       routeTable[ResourceName, Resource]
+	interceptors         interceptorChain
+	fallback             fallbackHandlers
+	shutdown             shutdownHooks
 }
 
 // NewAPI creates a new APIResource instance with the provided name.
 func NewAPI(name ResourceName) APIResource {
+	return newBaseAPIRes(name)
+}
+
+func newBaseAPIRes(name ResourceName) *BaseAPIRes {
 	bar := &BaseAPIRes{
-		name:           name,
-		LogHolder:      NewLogholder(name.String(), nil),
-		actionMap:      make(actionFuncMap),
-		resources:      make(ResourceMap),
-		childResources: make(ResourceMap),
-		id:             NumericID,
-	}
-	bar.SetHandler(bar.defaultHandler)
+		name:      name,
+		LogHolder: NewLogholder(name.String(), nil),
+	}
 	return bar
 }
 
@@ -144,8 +245,37 @@ func (bar *BaseAPIRes) Name() ResourceName {
 	return bar.name
 }
 
-func (bar *BaseAPIRes) defaultFunction(_ context.Context, w http.ResponseWriter, _ *http.Request) {
-	w.WriteHeader(http.StatusMethodNotAllowed)
+func (bar *BaseAPIRes) defaultFunction(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	w.Header().Set(headerAllow, strings.Join(bar.allowedMethods(ctx), ", "))
+	methodNotAllowed(ctx, w, req)
+}
+
+// defaultOptions answers an OPTIONS request with the methods allowed for the path.
+func (bar *BaseAPIRes) defaultOptions(ctx context.Context, w http.ResponseWriter, _ *http.Request) {
+	allowed := bar.allowedMethods(ctx)
+	w.Header().Set(headerAllow, strings.Join(allowed, ", "))
+	if slices.Contains(allowed, http.MethodPatch) {
+		bar.setAcceptPatch(w)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// allowedMethods returns the HTTP methods with handlers set for the request path.
+// GET is only allowed if the action it maps to for the path (List or Fetch, see whichAction) has a handler.
+func (bar *BaseAPIRes) allowedMethods(ctx context.Context) []string {
+	getAction := bar.whichAction(ctx, http.MethodGet)
+	actions := slices.DeleteFunc(bar.Actions(), func(at ActionType) bool {
+		return at.IsCustom() || (at == List || at == Fetch) && at != getAction
+	})
+	return bar.servedMethods(actions, routeMethods(actions))
+}
+
+func (bar *BaseAPIRes) SetNotFoundHandler(f ResweaveFunc) {
+	bar.fallback.setNotFound(f)
+}
+
+func (bar *BaseAPIRes) SetMethodNotAllowedHandler(f ResweaveFunc) {
+	bar.fallback.setMethodNotAllowed(f)
 }
 
 func (bar *BaseAPIRes) GetIDValue(ctx context.Context) (string, error) {
@@ -168,16 +298,32 @@ func (bar *BaseAPIRes) SetID(id ID) error {
 	if valid, err := id.IsValid(); !valid {
 		return err
 	}
-	bar.id = id
+	bar.id.Store(&id)
 	return nil
 }
 
-func (bar *BaseAPIRes) setFunction(at ActionType, f ResweaveFunc) {
-	if f == nil {
-		delete(bar.actionMap, at)
-		return
+func (bar *BaseAPIRes) GetID() ID {
+	if id := bar.id.Load(); id != nil {
+		return *id
 	}
-	bar.actionMap[at] = f
+	return NumericID
+}
+
+func (bar *BaseAPIRes) setFunction(at ActionType, f ResweaveFunc) {
+	_ = bar.actionMap.update(func(actions map[ActionType]ResweaveFunc) error {
+		if f == nil {
+			delete(actions, at)
+			return nil
+		}
+		actions[at] = f
+		return nil
+	})
+}
+
+func (bar *BaseAPIRes) Actions() []ActionType {
+	actions := slices.Collect(maps.Keys(bar.actionMap.load()))
+	slices.Sort(actions)
+	return actions
 }
 
 func (bar *BaseAPIRes) SetFetch(f ResweaveFunc) {
@@ -200,8 +346,20 @@ func (bar *BaseAPIRes) SetUpdate(f ResweaveFunc) {
 	bar.setFunction(Update, f)
 }
 
-func (bar *BaseAPIRes) unknownResource(_ context.Context, w http.ResponseWriter, _ *http.Request) {
-	w.WriteHeader(http.StatusNotFound)
+func (bar *BaseAPIRes) SetPatch(f ResweaveFunc) {
+	bar.setFunction(Patch, f)
+}
+
+func (bar *BaseAPIRes) SetHead(f ResweaveFunc) {
+	bar.setFunction(Head, f)
+}
+
+func (bar *BaseAPIRes) SetOptions(f ResweaveFunc) {
+	bar.setFunction(Options, f)
+}
+
+func (bar *BaseAPIRes) unknownResource(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	notFound(ctx, w, req)
 }
 
 func (bar *BaseAPIRes) popSegmentPaths(ctx context.Context, idSegment int) (context.Context, []ResourceName) {
@@ -250,7 +408,7 @@ func (bar *BaseAPIRes) storeID(c context.Context, req *http.Request) (context.Co
 		return context.WithValue(ctx, Key(fmt.Sprintf(keyPathHasSubSegment, bar.name.String())), false), 0, nil
 	}
 	ctx = context.WithValue(ctx, Key(fmt.Sprintf(keyPathHasSubSegment, bar.name.String())), true)
-	v, found := bar.id.Find(idVal)
+	v, found := bar.GetID().Find(idVal)
 	bar.Infow(curMethod, "idVal", idVal, "found", found, "v", v)
 	if !found {
 		// By allowing non-instanced sub-resources, it is now valid to have /resource/sub-resource as well as /resource/<id>/sub-resource.
@@ -278,8 +436,14 @@ func (bar *BaseAPIRes) whichAction(ctx context.Context, httpMethod string) Actio
 		return Create
 	case http.MethodDelete:
 		return Delete
-	case http.MethodPut, http.MethodPatch:
+	case http.MethodPut:
 		return Update
+	case http.MethodPatch:
+		return Patch
+	case http.MethodHead:
+		return Head
+	case http.MethodOptions:
+		return Options
 	default:
 		return unknown
 	}
@@ -287,10 +451,18 @@ func (bar *BaseAPIRes) whichAction(ctx context.Context, httpMethod string) Actio
 
 func (bar *BaseAPIRes) SetHandler(handler HandlerFunction) {
 	if handler == nil {
-		bar.handler = bar.defaultHandler
+		bar.handler.Store(nil)
 		return
 	}
-	bar.handler = handler
+	bar.handler.Store(&handler)
+}
+
+// getHandler returns the handler for this resource, defaulting to the action map based handler.
+func (bar *BaseAPIRes) getHandler() HandlerFunction {
+	if h := bar.handler.Load(); h != nil {
+		return *h
+	}
+	return bar.defaultHandler
 }
 
 func (bar *BaseAPIRes) findSubResource(c context.Context, w http.ResponseWriter, req *http.Request) (Resource, error) {
@@ -299,44 +471,131 @@ func (bar *BaseAPIRes) findSubResource(c context.Context, w http.ResponseWriter,
 		return nil, errors.New("no segments found")
 	}
 	if !bar.hasID(c) {
-		if res, found := bar.resources[segments[0]]; found {
+		if res, found := bar.resources.get(segments[0]); found {
 			return res, nil
 		}
 		return nil, errors.New("no sub-resource found")
 	}
-	if res, found := bar.childResources[segments[0]]; found {
+	if res, found := bar.childResources.get(segments[0]); found {
 		return res, nil
 	}
 	return nil, errors.New("no instanced sub-resource found")
 }
 
 func (bar *BaseAPIRes) HandleCall(c context.Context, w http.ResponseWriter, req *http.Request) {
+	ctx := bar.fallback.withContext(context.WithValue(c, KeyResource, Resource(bar)))
+	if bar.interceptors.empty() {
+		bar.handleCall(ctx, w, req)
+		return
+	}
+	bar.interceptors.handler(http.HandlerFunc(bar.serveHTTP)).ServeHTTP(w, req.WithContext(ctx))
+}
+
+// serveHTTP adapts handleCall to the end of the resource's interceptor chain.
+func (bar *BaseAPIRes) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	bar.handleCall(req.Context(), w, req)
+}
+
+func (bar *BaseAPIRes) AddInterceptor(f Interceptor) {
+	bar.interceptors.add(f, http.HandlerFunc(bar.serveHTTP))
+}
+
+func (bar *BaseAPIRes) handleCall(c context.Context, w http.ResponseWriter, req *http.Request) {
+	c, action, isAction := bar.customActionFromPath(c)
+	if isAction && action.Scope == CollectionScope {
+		bar.serveCustomAction(c, w, req, action)
+		return
+	}
 	ctx, idSegment, err := bar.storeID(c, req)
 	if err != nil {
 		bar.unknownResource(ctx, w, req)
 		return
 	}
 	ctx, segments := bar.popSegmentPaths(ctx, idSegment)
+	if isAction {
+		// <id>:<name>; the ID must be valid and the last segment.
+		if len(segments) > 0 || !bar.hasID(ctx) {
+			bar.unknownResource(ctx, w, req)
+			return
+		}
+		bar.serveCustomAction(ctx, w, req, action)
+		return
+	}
+	if action, isAction = bar.instanceAction(ctx, segments); isAction {
+		bar.serveCustomAction(ctx, w, req, action)
+		return
+	}
 	if len(segments) > 0 {
 		// Not at the lowest level resource need to keep going.
-		if res, err := bar.findSubResource(ctx, w, req); err != nil {
+		res, err := bar.findSubResource(ctx, w, req)
+		if err != nil {
 			bar.unknownResource(ctx, w, req)
-		} else {
-			res.HandleCall(ctx, w, req)
 			return
 		}
+		res.HandleCall(ctx, w, req)
+		return
 	}
 	at := bar.whichAction(ctx, req.Method)
-	if at == unknown {
+	_, found := bar.actionMap.get(at)
+	if at == List || at == Head && !found && bar.whichAction(ctx, http.MethodGet) == List {
+		if ctx, err = bar.withListQuery(ctx, req); err != nil {
+			WriteError(ctx, w, err)
+			return
+		}
+	}
+	switch {
+	case at == unknown, at == Patch && !found && bar.noPatchFallback.Load():
 		bar.defaultFunction(ctx, w, req)
 		return
+	case at == Options && !found:
+		bar.defaultOptions(ctx, w, req)
+		return
+	}
+	// The action to run: HEAD runs the GET action and PATCH falls back to Update, unless handled themselves.
+	served := at
+	switch {
+	case at == Head && !found:
+		served = bar.whichAction(ctx, http.MethodGet)
+	case at == Patch && !found:
+		served = Update
+	}
+	// Actions which are not served are answered with a 405 by the default handler, before the PATCH media type and
+	// the preconditions are checked, so the VersionFunc is not called for them. A custom HandlerFunction may serve
+	// any action.
+	_, isServed := bar.actionMap.get(served)
+	isServed = isServed || bar.handler.Load() != nil
+	if isServed && at == Patch && !bar.acceptsPatch(req) {
+		bar.unsupportedPatch(ctx, w, req)
+		return
+	}
+	conditional := served
+	if at == Patch {
+		conditional = Patch
+	}
+	if isServed && !bar.checkPreconditions(ctx, w, req, conditional) {
+		return
+	}
+	switch {
+	case at == Head && !found:
+		// Run the GET action, preserving its headers and status but discarding the body.
+		hw := newHeadResponseWriter(w)
+		bar.getHandler()(served, ctx, hw, req)
+		hw.finish()
+	case at == Patch && !found:
+		// Handlers written before Patch existed expect PATCH requests as Update.
+		bar.getHandler()(Update, ctx, w, req)
+	case at == Create:
+		bar.idempotent(ctx, w, req, func(w http.ResponseWriter, req *http.Request) {
+			bar.getHandler()(at, ctx, w, req)
+		})
+	default:
+		bar.getHandler()(at, ctx, w, req)
 	}
-	bar.handler(at, ctx, w, req)
 }
 
 func (bar *BaseAPIRes) defaultHandler(at ActionType, c context.Context, w http.ResponseWriter, req *http.Request) {
 	var fun ResweaveFunc = bar.defaultFunction
-	if f, found := bar.actionMap[at]; found {
+	if f, found := bar.actionMap.get(at); found {
 		fun = f
 	}
 	if at == List {
@@ -353,13 +612,18 @@ func (bar *BaseAPIRes) AddResource(r Resource) error {
 		return ErrNilResource
 	}
 
-	if _, found := bar.resources[r.Name()]; found {
-		bar.Infow("AddResource", "Name", r.Name(), "Exists?", found)
-		return fmt.Errorf(fmtResourceAlreadyExists, ErrResourceAlreadyExists, r.Name(), bar.Name())
+	err := bar.resources.update(func(resources map[ResourceName]Resource) error {
+		if _, found := resources[r.Name()]; found {
+			bar.Infow("AddResource", "Name", r.Name(), "Exists?", found)
+			return fmt.Errorf(fmtResourceAlreadyExists, ErrResourceAlreadyExists, r.Name(), bar.Name())
+		}
+		resources[r.Name()] = r
+		return nil
+	})
+	if err == nil {
+		bar.Infow("AddResource", "Name", fmt.Sprintf("'%s'", r.Name()), "Added", true)
 	}
-	bar.resources[r.Name()] = r
-	bar.Infow("AddResource", "Name", fmt.Sprintf("'%s'", r.Name()), "Added", true)
-	return nil
+	return err
 }
 
 func (bar *BaseAPIRes) AddChildResource(r Resource) error {
@@ -368,11 +632,105 @@ func (bar *BaseAPIRes) AddChildResource(r Resource) error {
 		return errors.New("cannot add a nil resource")
 	}
 
-	if _, found := bar.childResources[r.Name()]; found {
-		bar.Infow("AddChildResource", "Name", r.Name(), "Exists?", found)
-		return fmt.Errorf(fmtInstancedResourceAlreadyExists, ErrChildResourceAlreadyExists, r.Name(), bar.Name())
+	err := bar.childResources.update(func(resources map[ResourceName]Resource) error {
+		if _, found := resources[r.Name()]; found {
+			bar.Infow("AddChildResource", "Name", r.Name(), "Exists?", found)
+			return fmt.Errorf(fmtInstancedResourceAlreadyExists, ErrChildResourceAlreadyExists, r.Name(), bar.Name())
+		}
+		resources[r.Name()] = r
+		return nil
+	})
+	if err == nil {
+		bar.Infow("AddChildResource", "Name", fmt.Sprintf("'%s'", r.Name()), "Added", true)
 	}
-	bar.childResources[r.Name()] = r
-	bar.Infow("AddChildResource", "Name", fmt.Sprintf("'%s'", r.Name()), "Added", true)
-	return nil
+	return err
+}
+
+func (bar *BaseAPIRes) Resources() []Resource {
+	return sortedResources(bar.resources.load())
+}
+
+func (bar *BaseAPIRes) ChildResources() []Resource {
+	return sortedResources(bar.childResources.load())
+}
+
+func (bar *BaseAPIRes) RemoveResource(name ResourceName) (Resource, error) {
+	return bar.remove("RemoveResource", &bar.resources, name,
+		func() error { return fmt.Errorf(fmtResourceNotFound, ErrResourceNotFound, name, bar.Name()) })
+}
+
+func (bar *BaseAPIRes) ReplaceResource(r Resource) (Resource, error) {
+	if r == nil {
+		bar.Infow("ReplaceResource", "Error", "resource was nil")
+		return nil, ErrNilResource
+	}
+	return bar.replace("ReplaceResource", &bar.resources, r,
+		func() error { return fmt.Errorf(fmtResourceNotFound, ErrResourceNotFound, r.Name(), bar.Name()) })
+}
+
+func (bar *BaseAPIRes) RemoveChildResource(name ResourceName) (Resource, error) {
+	return bar.remove("RemoveChildResource", &bar.childResources, name,
+		func() error {
+			return fmt.Errorf(fmtInstancedResourceNotFound, ErrChildResourceNotFound, name, bar.Name())
+		})
+}
+
+func (bar *BaseAPIRes) ReplaceChildResource(r Resource) (Resource, error) {
+	if r == nil {
+		bar.Infow("ReplaceChildResource", "Error", "resource was nil")
+		return nil, ErrNilResource
+	}
+	return bar.replace("ReplaceChildResource", &bar.childResources, r,
+		func() error {
+			return fmt.Errorf(fmtInstancedResourceNotFound, ErrChildResourceNotFound, r.Name(), bar.Name())
+		})
+}
+
+func (bar *BaseAPIRes) remove(method string, table *routeTable[ResourceName, Resource], name ResourceName, notFound func() error) (Resource, error) {
+	var res Resource
+	err := table.update(func(resources map[ResourceName]Resource) error {
+		var found bool
+		if res, found = resources[name]; !found {
+			bar.Infow(method, "Name", name, "Exists?", found)
+			return notFound()
+		}
+		delete(resources, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	bar.Infow(method, "Name", fmt.Sprintf("'%s'", name), "Removed", true)
+	return res, nil
+}
+
+func (bar *BaseAPIRes) replace(method string, table *routeTable[ResourceName, Resource], r Resource, notFound func() error) (Resource, error) {
+	var old Resource
+	err := table.update(func(resources map[ResourceName]Resource) error {
+		var found bool
+		if old, found = resources[r.Name()]; !found {
+			bar.Infow(method, "Name", r.Name(), "Exists?", found)
+			return notFound()
+		}
+		resources[r.Name()] = r
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	bar.Infow(method, "Name", fmt.Sprintf("'%s'", r.Name()), "Replaced", true)
+	return old, nil
+}
+
+func (bar *BaseAPIRes) AddShutdownHook(hook ShutdownHook) {
+	bar.shutdown.add(hook)
+}
+
+func (bar *BaseAPIRes) Shutdown(ctx context.Context) error {
+	bar.Infow("Shutdown", "Name", bar.Name())
+	return errors.Join(
+		shutdownResources(ctx, bar.resources.load()),
+		shutdownResources(ctx, bar.childResources.load()),
+		bar.shutdown.run(ctx),
+	)
 }
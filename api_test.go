@@ -2,6 +2,8 @@ package resweave_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -126,7 +128,7 @@ var _ = Describe("Api", func() {
 			Expect(string(respData)).To(Equal(expContents))
 			respData, err = verifyStatusGetBody(http.StatusMethodNotAllowed, contextWithURISegments([]string{}), res, req2)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(string(respData)).To(Equal(""))
+			Expect(respData).To(MatchJSON(`{"title":"Method Not Allowed","status":405,"detail":"method not allowed: POST"}`))
 		})
 		It("should allow a Create function to be called", func() {
 			var s *zap.SugaredLogger
@@ -155,7 +157,7 @@ var _ = Describe("Api", func() {
 			Expect(string(respData)).To(Equal(expContents))
 			respData, err = verifyStatusGetBody(http.StatusMethodNotAllowed, contextWithURISegments([]string{}), res, req2)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(string(respData)).To(Equal(""))
+			Expect(respData).To(MatchJSON(`{"title":"Method Not Allowed","status":405,"detail":"method not allowed: GET"}`))
 		})
 		DescribeTable("should be possible to add all viable functions to an API resource",
 			func(method string, path string, ctx context.Context, expStatus int) {
@@ -372,6 +374,250 @@ var _ = Describe("Api", func() {
 			Expect(err).To(MatchError(resweave.ErrChildResourceAlreadyExists))
 		})
 	})
+	var _ = Describe("RemoveResource & ReplaceResource", func() {
+		var (
+			res    resweave.APIResource
+			subRes resweave.APIResource
+		)
+		BeforeEach(func() {
+			res = resweave.NewAPI("foo")
+			subRes = resweave.NewAPI("bar")
+			Expect(res.AddResource(subRes)).To(Succeed())
+		})
+		It("should be possible to remove a sub-resource", func() {
+			removed, err := res.RemoveResource("bar")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(removed).To(Equal(subRes))
+			req := httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+			_, _ = verifyStatusGetBody(http.StatusNotFound, contextWithURISegments([]string{"foo", "bar"}), res, req)
+			_, err = res.RemoveResource("bar")
+			Expect(err).To(MatchError(resweave.ErrResourceNotFound))
+		})
+		It("should be possible to replace a sub-resource", func() {
+			replacement := resweave.NewAPI("bar")
+			replacement.SetList(func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			})
+			old, err := res.ReplaceResource(replacement)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(old).To(Equal(subRes))
+			req := httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+			_, _ = verifyStatusGetBody(http.StatusTeapot, contextWithURISegments([]string{"foo", "bar"}), res, req)
+		})
+		It("should error when replacing a sub-resource which does not exist", func() {
+			_, err := res.ReplaceResource(resweave.NewAPI("other"))
+			Expect(err).To(MatchError(resweave.ErrResourceNotFound))
+			_, err = res.ReplaceResource(nil)
+			Expect(err).To(MatchError(resweave.ErrNilResource))
+		})
+		It("should be possible to remove and replace a child resource", func() {
+			child := resweave.NewAPI("baz")
+			Expect(res.AddChildResource(child)).To(Succeed())
+			replacement := resweave.NewAPI("baz")
+			replacement.SetList(func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			})
+			old, err := res.ReplaceChildResource(replacement)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(old).To(Equal(child))
+			req := httptest.NewRequest(http.MethodGet, "/foo/1/baz", nil)
+			_, _ = verifyStatusGetBody(http.StatusTeapot, contextWithURISegments([]string{"foo", "1", "baz"}), res, req)
+
+			removed, err := res.RemoveChildResource("baz")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(removed).To(Equal(replacement))
+			_, _ = verifyStatusGetBody(http.StatusNotFound, contextWithURISegments([]string{"foo", "1", "baz"}), res, req)
+			_, err = res.RemoveChildResource("baz")
+			Expect(err).To(MatchError(resweave.ErrChildResourceNotFound))
+			_, err = res.ReplaceChildResource(replacement)
+			Expect(err).To(MatchError(resweave.ErrChildResourceNotFound))
+		})
+	})
+
+	var _ = Describe("Shutdown", func() {
+		It("should run sub-resource and child resource hooks before its own", func() {
+			var order []string
+			hook := func(name string) resweave.ShutdownHook {
+				return func(context.Context) error {
+					order = append(order, name)
+					return nil
+				}
+			}
+			res := resweave.NewAPI("foo")
+			subRes := resweave.NewAPI("bar")
+			childRes := resweave.NewAPI("baz")
+			res.AddShutdownHook(hook("foo"))
+			subRes.AddShutdownHook(hook("bar"))
+			childRes.AddShutdownHook(hook("baz"))
+			Expect(res.AddResource(subRes)).To(Succeed())
+			Expect(res.AddChildResource(childRes)).To(Succeed())
+
+			Expect(res.Shutdown(context.Background())).To(Succeed())
+			Expect(order).To(Equal([]string{"bar", "baz", "foo"}))
+		})
+		It("should run every hook and join their errors", func() {
+			errOne := errors.New("one")
+			errTwo := errors.New("two")
+			res := resweave.NewAPI("foo")
+			res.AddShutdownHook(func(context.Context) error { return errOne })
+			res.AddShutdownHook(func(context.Context) error { return errTwo })
+			err := res.Shutdown(context.Background())
+			Expect(err).To(MatchError(errOne))
+			Expect(err).To(MatchError(errTwo))
+		})
+	})
+
+	var _ = Describe("HEAD & OPTIONS", func() {
+		var (
+			res resweave.APIResource
+		)
+		const body = `[{"id":1}]`
+		handle := func(method string, path string, segments []string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			res.HandleCall(contextWithURISegments(segments), recorder, httptest.NewRequest(method, path, nil))
+			return recorder
+		}
+		BeforeEach(func() {
+			res = resweave.NewAPI("users")
+			res.SetList(func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("X-Total-Count", "1")
+				_, _ = w.Write([]byte(body))
+			})
+			res.SetCreate(func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+			})
+		})
+		It("should run the List handler for HEAD, discarding the body", func() {
+			rec := handle(http.MethodHead, "/users", []string{"users"})
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(BeEmpty())
+			Expect(rec.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(rec.Header().Get("X-Total-Count")).To(Equal("1"))
+			Expect(rec.Header().Get("Content-Length")).To(Equal(fmt.Sprint(len(body))))
+		})
+		It("should run the Fetch handler for HEAD with an ID, preserving its status", func() {
+			res.SetFetch(func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Length", "42")
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte("not found"))
+			})
+			rec := handle(http.MethodHead, "/users/1", []string{"users", "1"})
+			Expect(rec.Code).To(Equal(http.StatusNotFound))
+			Expect(rec.Body.String()).To(BeEmpty())
+			Expect(rec.Header().Get("Content-Length")).To(Equal("42"))
+		})
+		It("should not set a Content-Length for HEAD responses without content", func() {
+			res.SetFetch(func(_ context.Context, w http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/users/1" {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				w.WriteHeader(http.StatusNotModified)
+			})
+			rec := handle(http.MethodHead, "/users/1", []string{"users", "1"})
+			Expect(rec.Code).To(Equal(http.StatusNoContent))
+			Expect(rec.Header()).ToNot(HaveKey("Content-Length"))
+
+			rec = handle(http.MethodHead, "/users/2", []string{"users", "2"})
+			Expect(rec.Code).To(Equal(http.StatusNotModified))
+			Expect(rec.Header()).ToNot(HaveKey("Content-Length"))
+		})
+		It("should answer HEAD with 405 when there is no GET handler", func() {
+			rec := handle(http.MethodHead, "/users/1", []string{"users", "1"})
+			Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(rec.Body.String()).To(BeEmpty())
+		})
+		It("should answer OPTIONS with the allowed methods", func() {
+			rec := handle(http.MethodOptions, "/users", []string{"users"})
+			Expect(rec.Code).To(Equal(http.StatusNoContent))
+			Expect(rec.Header().Get("Allow")).To(Equal("GET, HEAD, OPTIONS, POST"))
+
+			rec = handle(http.MethodOptions, "/users/1", []string{"users", "1"})
+			Expect(rec.Code).To(Equal(http.StatusNoContent))
+			Expect(rec.Header().Get("Allow")).To(Equal("OPTIONS, POST"))
+		})
+		It("should be possible to override HEAD and OPTIONS", func() {
+			res.SetHead(func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			})
+			res.SetOptions(func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Access-Control-Allow-Methods", "GET")
+				w.WriteHeader(http.StatusOK)
+			})
+			Expect(handle(http.MethodHead, "/users", []string{"users"}).Code).To(Equal(http.StatusTeapot))
+			rec := handle(http.MethodOptions, "/users", []string{"users"})
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Header().Get("Access-Control-Allow-Methods")).To(Equal("GET"))
+			Expect(res.Actions()).To(ContainElements(resweave.Head, resweave.Options))
+		})
+	})
+
+	var _ = Describe("PATCH", func() {
+		var (
+			res    resweave.APIResource
+			called resweave.ActionType
+		)
+		record := func(at resweave.ActionType) resweave.ResweaveFunc {
+			return func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+				called = at
+				w.WriteHeader(http.StatusOK)
+			}
+		}
+		patch := func(contentType string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPatch, "/users/1", strings.NewReader(`{}`))
+			req.Header.Set("Content-Type", contentType)
+			res.HandleCall(contextWithURISegments([]string{"users", "1"}), recorder, req)
+			return recorder
+		}
+		BeforeEach(func() {
+			called = 0
+			res = resweave.NewAPI("users")
+			res.SetUpdate(record(resweave.Update))
+		})
+		It("should fall back to the Update handler when no Patch handler is set", func() {
+			Expect(patch("application/json").Code).To(Equal(http.StatusOK))
+			Expect(called).To(Equal(resweave.Update))
+
+			var got resweave.ActionType
+			res.SetHandler(func(at resweave.ActionType, _ context.Context, _ http.ResponseWriter, _ *http.Request) {
+				got = at
+			})
+			patch("application/json")
+			Expect(got).To(Equal(resweave.Update))
+		})
+		It("should use the Patch handler when set", func() {
+			res.SetPatch(record(resweave.Patch))
+			Expect(patch("application/json").Code).To(Equal(http.StatusOK))
+			Expect(called).To(Equal(resweave.Patch))
+			Expect(res.Actions()).To(ContainElement(resweave.Patch))
+			Expect(resweave.Patch.String()).To(Equal("Patch"))
+		})
+		It("should advertise and enforce the declared media types", func() {
+			res.SetPatch(record(resweave.Patch))
+			res.SetPatchMediaTypes("application/merge-patch+json", "application/json-patch+json")
+
+			rec := httptest.NewRecorder()
+			res.HandleCall(contextWithURISegments([]string{"users", "1"}), rec, httptest.NewRequest(http.MethodOptions, "/users/1", nil))
+			Expect(rec.Header().Get("Accept-Patch")).To(Equal("application/merge-patch+json, application/json-patch+json"))
+
+			rec = patch("application/json")
+			Expect(rec.Code).To(Equal(http.StatusUnsupportedMediaType))
+			Expect(rec.Header().Get("Accept-Patch")).To(Equal("application/merge-patch+json, application/json-patch+json"))
+			Expect(called).To(BeZero())
+
+			Expect(patch("application/merge-patch+json; charset=utf-8").Code).To(Equal(http.StatusOK))
+			Expect(called).To(Equal(resweave.Patch))
+		})
+		It("should not advertise Accept-Patch where PATCH is not allowed", func() {
+			res.SetPatchMediaTypes("application/merge-patch+json")
+			res.SetUpdate(nil)
+			rec := httptest.NewRecorder()
+			res.HandleCall(contextWithURISegments([]string{"users", "1"}), rec, httptest.NewRequest(http.MethodOptions, "/users/1", nil))
+			Expect(rec.Header().Get("Accept-Patch")).To(BeEmpty())
+		})
+	})
 })
 
 func verifyStatusGetBody(expStatusCode int, inputContext context.Context, res resweave.Resource, req *http.Request) ([]byte, error) {
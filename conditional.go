@@ -0,0 +1,195 @@
+package resweave
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	headerETag              = "ETag"
+	headerLastModified      = "Last-Modified"
+	headerIfMatch           = "If-Match"
+	headerIfNoneMatch       = "If-None-Match"
+	headerIfModifiedSince   = "If-Modified-Since"
+	headerIfUnmodifiedSince = "If-Unmodified-Since"
+	etagWeakPrefix          = "W/"
+	etagAny                 = "*"
+)
+
+var (
+	// ErrPreconditionFailed is written when a conditional request header does not match the current Version; it is
+	// answered with a 412.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrPreconditionRequired is written when an Update, Patch or Delete request has no If-Match or
+	// If-Unmodified-Since header and preconditions are required; it is answered with a 428.
+	ErrPreconditionRequired = errors.New("precondition required")
+)
+
+// Version is the current version of the entity, or collection, at a path; see APIResource.SetVersion.
+type Version struct {
+	// ETag is the opaque entity tag, without quotes.
+	ETag string
+	// Weak marks the ETag as a weak validator, which never matches If-Match.
+	Weak bool
+	// LastModified is the time the entity was last modified, if known.
+	LastModified time.Time
+}
+
+// VersionFunc returns the current Version at the request path. The ID, if any, is available from the context as for
+// any handler. Returning an error wrapping ErrNotFound indicates nothing exists at the path.
+type VersionFunc func(ctx context.Context, req *http.Request) (Version, error)
+
+// String returns the ETag as it appears in headers, e.g. W/"v1".
+func (v Version) String() string {
+	if v.ETag == "" {
+		return ""
+	}
+	tag := `"` + v.ETag + `"`
+	if v.Weak {
+		return etagWeakPrefix + tag
+	}
+	return tag
+}
+
+// setHeaders sets the ETag and Last-Modified headers for the version.
+func (v Version) setHeaders(w http.ResponseWriter) {
+	if v.ETag != "" {
+		w.Header().Set(headerETag, v.String())
+	}
+	if !v.LastModified.IsZero() {
+		w.Header().Set(headerLastModified, v.LastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// entityTag is an entity tag from an If-Match or If-None-Match header.
+type entityTag struct {
+	tag  string
+	weak bool
+}
+
+// parseETags parses a comma separated list of entity tags, returning wildcard as true for "*".
+func parseETags(header string) (tags []entityTag, wildcard bool) {
+	s := strings.TrimSpace(header)
+	if s == etagAny {
+		return nil, true
+	}
+	for s != "" {
+		s = strings.TrimLeft(s, " \t,")
+		var et entityTag
+		if rest, found := strings.CutPrefix(s, etagWeakPrefix); found {
+			et.weak, s = true, rest
+		}
+		if !strings.HasPrefix(s, `"`) {
+			break
+		}
+		end := strings.Index(s[1:], `"`)
+		if end < 0 {
+			break
+		}
+		et.tag, s = s[1:end+1], s[end+2:]
+		tags = append(tags, et)
+	}
+	return tags, false
+}
+
+// matches compares the header entity tags with the version; strong comparison requires neither to be weak.
+func (v Version) matches(header string, strong bool) bool {
+	tags, wildcard := parseETags(header)
+	if wildcard {
+		return true
+	}
+	for _, et := range tags {
+		if v.ETag == "" || et.tag != v.ETag {
+			continue
+		}
+		if !strong || (!et.weak && !v.Weak) {
+			return true
+		}
+	}
+	return false
+}
+
+// modifiedSince returns whether the version was modified after the HTTP date in the header, and false for ok if the
+// date is invalid or the modification time is unknown, in which case the header must be ignored.
+func (v Version) modifiedSince(header string) (modified bool, ok bool) {
+	t, err := http.ParseTime(header)
+	if err != nil || v.LastModified.IsZero() {
+		return false, false
+	}
+	return v.LastModified.Truncate(time.Second).After(t), true
+}
+
+func (bar *BaseAPIRes) SetVersion(f VersionFunc) {
+	if f == nil {
+		bar.version.Store(nil)
+		return
+	}
+	bar.version.Store(&f)
+}
+
+func (bar *BaseAPIRes) SetRequirePreconditions(required bool) {
+	bar.requirePreconditions.Store(required)
+}
+
+// checkPreconditions evaluates the conditional request headers against the current Version, in the order of
+// RFC 9110 section 13.2.2, for reads (List, Fetch and HEAD of either) and writes (Update, Patch and Delete).
+// It returns false if it has responded to the request.
+func (bar *BaseAPIRes) checkPreconditions(ctx context.Context, w http.ResponseWriter, req *http.Request, at ActionType) bool {
+	read := at == List || at == Fetch
+	write := at == Update || at == Patch || at == Delete
+	vf := bar.version.Load()
+	if vf == nil || !read && !write {
+		return true
+	}
+	h := req.Header
+	if write && bar.requirePreconditions.Load() && h.Get(headerIfMatch) == "" && h.Get(headerIfUnmodifiedSince) == "" {
+		WriteError(ctx, w, ErrPreconditionRequired)
+		return false
+	}
+	v, err := (*vf)(ctx, req)
+	exists := err == nil
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		WriteError(ctx, w, err)
+		return false
+	}
+
+	switch {
+	case h.Get(headerIfMatch) != "":
+		if !exists || !v.matches(h.Get(headerIfMatch), true) {
+			WriteError(ctx, w, ErrPreconditionFailed)
+			return false
+		}
+	case h.Get(headerIfUnmodifiedSince) != "" && exists:
+		if modified, ok := v.modifiedSince(h.Get(headerIfUnmodifiedSince)); ok && modified {
+			WriteError(ctx, w, ErrPreconditionFailed)
+			return false
+		}
+	}
+
+	notModified := false
+	switch {
+	case h.Get(headerIfNoneMatch) != "":
+		if exists && v.matches(h.Get(headerIfNoneMatch), false) {
+			if write {
+				WriteError(ctx, w, ErrPreconditionFailed)
+				return false
+			}
+			notModified = true
+		}
+	case read && h.Get(headerIfModifiedSince) != "" && exists:
+		modified, ok := v.modifiedSince(h.Get(headerIfModifiedSince))
+		notModified = ok && !modified
+	}
+
+	if read && exists {
+		v.setHeaders(w)
+	}
+	if notModified {
+		w.WriteHeader(http.StatusNotModified)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,184 @@
+package resweave_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/mortedecai/resweave"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Conditional Requests", func() {
+	var (
+		s        resweave.Server
+		docs     resweave.APIResource
+		versions map[string]int
+		modified time.Time
+		called   bool
+	)
+	do := func(method string, target string, headers ...string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, nil)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		s.ServeHTTP(recorder, req)
+		return recorder
+	}
+	ok := func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}
+
+	BeforeEach(func() {
+		called = false
+		modified = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+		versions = map[string]int{"1": 3}
+		s = resweave.NewServer(0)
+		docs = resweave.NewAPI("docs")
+		docs.SetList(ok)
+		docs.SetFetch(ok)
+		docs.SetUpdate(ok)
+		docs.SetDelete(ok)
+		docs.SetVersion(func(ctx context.Context, _ *http.Request) (resweave.Version, error) {
+			id, err := docs.GetIDValue(ctx)
+			if err != nil {
+				return resweave.Version{ETag: "list", Weak: true}, nil
+			}
+			v, found := versions[id]
+			if !found {
+				return resweave.Version{}, fmt.Errorf("doc %s: %w", id, resweave.ErrNotFound)
+			}
+			return resweave.Version{ETag: strconv.Itoa(v), LastModified: modified}, nil
+		})
+		Expect(s.AddResource(docs)).To(Succeed())
+	})
+
+	Describe("Reads", func() {
+		It("should set the ETag and Last-Modified headers", func() {
+			rec := do(http.MethodGet, "/docs/1")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Header().Get("ETag")).To(Equal(`"3"`))
+			Expect(rec.Header().Get("Last-Modified")).To(Equal("Thu, 01 Oct 2026 12:00:00 GMT"))
+			Expect(do(http.MethodGet, "/docs").Header().Get("ETag")).To(Equal(`W/"list"`))
+		})
+
+		It("should answer a matching If-None-Match with a 304", func() {
+			rec := do(http.MethodGet, "/docs/1", "If-None-Match", `"1", W/"3"`)
+			Expect(rec.Code).To(Equal(http.StatusNotModified))
+			Expect(rec.Header().Get("ETag")).To(Equal(`"3"`))
+			Expect(rec.Body.String()).To(BeEmpty())
+			Expect(called).To(BeFalse())
+
+			Expect(do(http.MethodGet, "/docs", "If-None-Match", `"list"`).Code).To(Equal(http.StatusNotModified))
+			Expect(do(http.MethodHead, "/docs/1", "If-None-Match", "*").Code).To(Equal(http.StatusNotModified))
+		})
+
+		It("should call the handler when If-None-Match does not match", func() {
+			Expect(do(http.MethodGet, "/docs/1", "If-None-Match", `"2"`).Code).To(Equal(http.StatusOK))
+			Expect(called).To(BeTrue())
+		})
+
+		It("should use If-Modified-Since only without If-None-Match", func() {
+			Expect(do(http.MethodGet, "/docs/1", "If-Modified-Since", "Thu, 01 Oct 2026 12:00:00 GMT").Code).To(Equal(http.StatusNotModified))
+			Expect(do(http.MethodGet, "/docs/1", "If-Modified-Since", "Wed, 30 Sep 2026 12:00:00 GMT").Code).To(Equal(http.StatusOK))
+			Expect(do(http.MethodGet, "/docs/1", "If-Modified-Since", "yesterday").Code).To(Equal(http.StatusOK))
+			Expect(do(http.MethodGet, "/docs/1",
+				"If-None-Match", `"2"`, "If-Modified-Since", "Thu, 01 Oct 2026 12:00:00 GMT").Code).To(Equal(http.StatusOK))
+		})
+
+		It("should leave missing entities to the handler", func() {
+			rec := do(http.MethodGet, "/docs/2", "If-None-Match", "*")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Header().Get("ETag")).To(BeEmpty())
+		})
+	})
+
+	Describe("Writes", func() {
+		It("should answer a mismatched If-Match with a 412 problem", func() {
+			rec := do(http.MethodPut, "/docs/1", "If-Match", `"2"`)
+			Expect(rec.Code).To(Equal(http.StatusPreconditionFailed))
+			Expect(rec.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+			Expect(called).To(BeFalse())
+
+			Expect(do(http.MethodDelete, "/docs/1", "If-Match", `W/"3"`).Code).To(Equal(http.StatusPreconditionFailed))
+			Expect(do(http.MethodPut, "/docs/2", "If-Match", "*").Code).To(Equal(http.StatusPreconditionFailed))
+			Expect(called).To(BeFalse())
+		})
+
+		It("should call the handler when If-Match matches", func() {
+			Expect(do(http.MethodPut, "/docs/1", "If-Match", `"2", "3"`).Code).To(Equal(http.StatusOK))
+			Expect(called).To(BeTrue())
+			Expect(do(http.MethodPatch, "/docs/1", "If-Match", "*").Code).To(Equal(http.StatusOK))
+		})
+
+		It("should check If-Unmodified-Since", func() {
+			Expect(do(http.MethodDelete, "/docs/1", "If-Unmodified-Since", "Wed, 30 Sep 2026 12:00:00 GMT").Code).To(Equal(http.StatusPreconditionFailed))
+			Expect(do(http.MethodDelete, "/docs/1", "If-Unmodified-Since", "Thu, 01 Oct 2026 12:00:00 GMT").Code).To(Equal(http.StatusOK))
+			Expect(do(http.MethodDelete, "/docs/1", "If-Unmodified-Since", "yesterday").Code).To(Equal(http.StatusOK))
+		})
+
+		It("should answer a matching If-None-Match with a 412", func() {
+			Expect(do(http.MethodPut, "/docs/1", "If-None-Match", "*").Code).To(Equal(http.StatusPreconditionFailed))
+			Expect(do(http.MethodPut, "/docs/2", "If-None-Match", "*").Code).To(Equal(http.StatusOK))
+		})
+
+		It("should answer writes without a precondition with a 428 when required", func() {
+			Expect(do(http.MethodPut, "/docs/1").Code).To(Equal(http.StatusOK))
+
+			called = false
+			docs.SetRequirePreconditions(true)
+			rec := do(http.MethodPut, "/docs/1")
+			Expect(rec.Code).To(Equal(http.StatusPreconditionRequired))
+			Expect(called).To(BeFalse())
+			Expect(do(http.MethodGet, "/docs/1").Code).To(Equal(http.StatusOK))
+			Expect(do(http.MethodDelete, "/docs/1", "If-Match", `"3"`).Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	It("should answer actions without a handler with a 405 before checking preconditions", func() {
+		versionCalls := 0
+		readOnly := resweave.NewAPI("notes")
+		readOnly.SetFetch(ok)
+		readOnly.SetVersion(func(context.Context, *http.Request) (resweave.Version, error) {
+			versionCalls++
+			return resweave.Version{ETag: "1"}, nil
+		})
+		readOnly.SetRequirePreconditions(true)
+		readOnly.SetPatchMediaTypes("application/merge-patch+json")
+		Expect(s.AddResource(readOnly)).To(Succeed())
+
+		rec := do(http.MethodDelete, "/notes/1")
+		Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(rec.Header().Get("Allow")).To(Equal("GET, HEAD, OPTIONS"))
+		rec = do(http.MethodPut, "/notes/1", "If-Match", `"2"`)
+		Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(rec.Header().Get("Allow")).To(Equal("GET, HEAD, OPTIONS"))
+		Expect(do(http.MethodPatch, "/notes/1", "If-Match", `"2"`).Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(versionCalls).To(BeZero())
+		Expect(called).To(BeFalse())
+
+		docs.SetPatchMediaTypes("application/merge-patch+json")
+		Expect(do(http.MethodPatch, "/docs/1", "If-Match", `"2"`, "Content-Type", "text/plain").Code).
+			To(Equal(http.StatusUnsupportedMediaType))
+		Expect(do(http.MethodPatch, "/docs/1", "If-Match", `"2"`, "Content-Type", "application/merge-patch+json").Code).
+			To(Equal(http.StatusPreconditionFailed))
+
+		Expect(do(http.MethodGet, "/notes/1", "If-None-Match", `"1"`).Code).To(Equal(http.StatusNotModified))
+		Expect(versionCalls).To(Equal(1))
+	})
+
+	It("should be disabled without a VersionFunc", func() {
+		docs.SetVersion(nil)
+		docs.SetRequirePreconditions(true)
+		Expect(do(http.MethodPut, "/docs/1", "If-Match", `"2"`).Code).To(Equal(http.StatusOK))
+		rec := do(http.MethodGet, "/docs/1", "If-None-Match", `"3"`)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("ETag")).To(BeEmpty())
+	})
+})
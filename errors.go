@@ -1,6 +1,34 @@
 package resweave
 
+import "errors"
+
 const (
 	// FmtResourceAlreadyExists is the format string for a resource not existing in the Host
 	FmtResourceAlreadyExists = "resource '%s' already exists on host '%s'"
+
+	fmtResourceNotFoundOnHost = "%w: '%s' on host '%s'"
+	fmtHostNotFound           = "%w: '%s'"
 )
+
+var (
+	// ErrShutdownDeadlineExceeded is returned by Server.Shutdown when active requests did not complete before the deadline.
+	ErrShutdownDeadlineExceeded = errors.New("server shutdown deadline exceeded")
+	// ErrHostNotFound is returned when removing a Host which has not been added to the Server.
+	ErrHostNotFound = errors.New("host not found")
+	// ErrDefaultHostRemoval is returned when attempting to remove the Server's default Host.
+	ErrDefaultHostRemoval = errors.New("the default host cannot be removed")
+	// ErrInvalidRedirectCode is returned by Host.SetCanonicalRedirect for status codes other than 301 and 308.
+	ErrInvalidRedirectCode = errors.New("invalid canonical redirect status code")
+	// ErrNotFound may be returned by TypedHandlers when the requested item does not exist; it is answered with a 404.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict may be returned by TypedHandlers when the request conflicts with the current state; it is answered
+	// with a 409.
+	ErrConflict = errors.New("conflict")
+	// ErrInvalid may be returned by TypedHandlers when the request content is invalid; it is answered with a 400.
+	ErrInvalid = errors.New("invalid request")
+)
+
+// StatusCoder may be implemented by errors returned from TypedHandlers to choose the HTTP status of the response.
+type StatusCoder interface {
+	StatusCode() int
+}
@@ -0,0 +1,67 @@
+package resweave
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+const (
+	keyNotFoundHandler         = Key("NOT_FOUND_HANDLER")
+	keyMethodNotAllowedHandler = Key("METHOD_NOT_ALLOWED_HANDLER")
+	headerAllow                = "Allow"
+)
+
+// fallbackHandlers holds the not found and method not allowed handlers set on a Server, Host or Resource.
+// Handlers are passed down the request context, so those set lower down override those set higher up.
+type fallbackHandlers struct {
+	notFound         atomic.Pointer[ResweaveFunc]
+	methodNotAllowed atomic.Pointer[ResweaveFunc]
+}
+
+func storeFallback(p *atomic.Pointer[ResweaveFunc], f ResweaveFunc) {
+	if f == nil {
+		p.Store(nil)
+		return
+	}
+	p.Store(&f)
+}
+
+func (fh *fallbackHandlers) setNotFound(f ResweaveFunc) {
+	storeFallback(&fh.notFound, f)
+}
+
+func (fh *fallbackHandlers) setMethodNotAllowed(f ResweaveFunc) {
+	storeFallback(&fh.methodNotAllowed, f)
+}
+
+// withContext returns ctx carrying any handlers which have been set.
+func (fh *fallbackHandlers) withContext(ctx context.Context) context.Context {
+	if f := fh.notFound.Load(); f != nil {
+		ctx = context.WithValue(ctx, keyNotFoundHandler, *f)
+	}
+	if f := fh.methodNotAllowed.Load(); f != nil {
+		ctx = context.WithValue(ctx, keyMethodNotAllowedHandler, *f)
+	}
+	return ctx
+}
+
+// notFound responds using the closest not found handler in the context, defaulting to a 404 problem.
+func notFound(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	if f, ok := ctx.Value(keyNotFoundHandler).(ResweaveFunc); ok {
+		f(ctx, w, req)
+		return
+	}
+	WriteError(ctx, w, ErrResourceNotFound)
+}
+
+// methodNotAllowed responds using the closest method not allowed handler in the context, defaulting to a 405 problem.
+// The Allow header must already have been set.
+func methodNotAllowed(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	if f, ok := ctx.Value(keyMethodNotAllowedHandler).(ResweaveFunc); ok {
+		f(ctx, w, req)
+		return
+	}
+	WriteError(ctx, w, fmt.Errorf("%w: %s", ErrMethodNotAllowed, req.Method))
+}
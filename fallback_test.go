@@ -0,0 +1,137 @@
+package resweave_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/mortedecai/resweave"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fallback Handlers", func() {
+	var (
+		s     resweave.Server
+		h     resweave.Host
+		users resweave.APIResource
+	)
+	// respond returns a ResweaveFunc answering with the status and body.
+	respond := func(status int, body string) resweave.ResweaveFunc {
+		return func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		}
+	}
+	do := func(method string, target string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+		return recorder
+	}
+
+	BeforeEach(func() {
+		s = resweave.NewServer(0)
+		var err error
+		h, err = s.AddHost("api.example.com")
+		Expect(err).ToNot(HaveOccurred())
+		users = resweave.NewAPI("users")
+		users.SetList(respond(http.StatusOK, "list"))
+		users.SetCreate(respond(http.StatusCreated, "create"))
+		users.SetFetch(respond(http.StatusOK, "fetch"))
+		Expect(h.AddResource(users)).To(Succeed())
+	})
+
+	Describe("Defaults", func() {
+		It("should write a 404 problem for unknown resources", func() {
+			rec := do(http.MethodGet, "http://api.example.com/missing")
+			Expect(rec.Code).To(Equal(http.StatusNotFound))
+			Expect(rec.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+			var p resweave.Problem
+			Expect(json.Unmarshal(rec.Body.Bytes(), &p)).To(Succeed())
+			Expect(p.Status).To(Equal(http.StatusNotFound))
+			Expect(p.Title).To(Equal("Not Found"))
+			Expect(p.Instance).To(Equal("/missing"))
+			Expect(p.RequestID).ToNot(BeEmpty())
+		})
+		It("should not call a handler after an unknown sub-resource", func() {
+			rec := do(http.MethodPost, "http://api.example.com/users/unknown")
+			Expect(rec.Code).To(Equal(http.StatusNotFound))
+			Expect(rec.Body.String()).ToNot(ContainSubstring("create"))
+		})
+		It("should write a 405 problem for actions without a handler", func() {
+			rec := do(http.MethodDelete, "http://api.example.com/users")
+			Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(rec.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+			Expect(rec.Body.String()).To(ContainSubstring(`"detail":"method not allowed: DELETE"`))
+		})
+		It("should list the methods with handlers set in the Allow header of a 405", func() {
+			rec := do(http.MethodDelete, "http://api.example.com/users")
+			Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(rec.Header().Get("Allow")).To(Equal("GET, HEAD, OPTIONS, POST"))
+
+			users.SetUpdate(respond(http.StatusOK, "update"))
+			rec = do(http.MethodDelete, "http://api.example.com/users/1")
+			Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(rec.Header().Get("Allow")).To(Equal("GET, HEAD, OPTIONS, PATCH, POST, PUT"))
+		})
+		It("should only allow GET where the List or Fetch handler it maps to is set", func() {
+			users.SetList(nil)
+			rec := do(http.MethodGet, "http://api.example.com/users")
+			Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(rec.Header().Get("Allow")).To(Equal("OPTIONS, POST"))
+
+			users.SetList(respond(http.StatusOK, "list"))
+			users.SetFetch(nil)
+			rec = do(http.MethodGet, "http://api.example.com/users/1")
+			Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(rec.Header().Get("Allow")).To(Equal("OPTIONS, POST"))
+		})
+		It("should only allow OPTIONS when no handlers are set", func() {
+			Expect(s.AddResource(resweave.NewAPI("empty"))).To(Succeed())
+			rec := do(http.MethodGet, "/empty")
+			Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(rec.Header().Get("Allow")).To(Equal("OPTIONS"))
+		})
+	})
+
+	Describe("Overrides", func() {
+		BeforeEach(func() {
+			s.SetNotFoundHandler(respond(http.StatusNotFound, "server"))
+			s.SetMethodNotAllowedHandler(respond(http.StatusMethodNotAllowed, "server"))
+		})
+		It("should use the server handlers by default", func() {
+			Expect(do(http.MethodGet, "/missing").Body.String()).To(Equal("server"))
+			Expect(do(http.MethodGet, "http://api.example.com/missing").Body.String()).To(Equal("server"))
+			Expect(do(http.MethodDelete, "http://api.example.com/users").Body.String()).To(Equal("server"))
+		})
+		It("should prefer the host handlers on that host", func() {
+			h.SetNotFoundHandler(respond(http.StatusNotFound, "host"))
+			h.SetMethodNotAllowedHandler(respond(http.StatusMethodNotAllowed, "host"))
+			Expect(do(http.MethodGet, "http://api.example.com/missing").Body.String()).To(Equal("host"))
+			Expect(do(http.MethodDelete, "http://api.example.com/users").Body.String()).To(Equal("host"))
+			Expect(do(http.MethodGet, "/missing").Body.String()).To(Equal("server"))
+		})
+		It("should prefer the resource handlers within the resource", func() {
+			h.SetNotFoundHandler(respond(http.StatusNotFound, "host"))
+			users.SetNotFoundHandler(respond(http.StatusNotFound, "users"))
+			users.SetMethodNotAllowedHandler(func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				_, _ = w.Write([]byte("users: " + w.Header().Get("Allow")))
+			})
+			Expect(do(http.MethodGet, "http://api.example.com/users/1/missing").Body.String()).To(Equal("users"))
+			Expect(do(http.MethodDelete, "http://api.example.com/users").Body.String()).To(Equal("users: GET, HEAD, OPTIONS, POST"))
+			Expect(do(http.MethodGet, "http://api.example.com/missing").Body.String()).To(Equal("host"))
+		})
+		It("should apply resource handlers to sub-resources", func() {
+			users.SetMethodNotAllowedHandler(respond(http.StatusMethodNotAllowed, "users"))
+			Expect(users.AddChildResource(resweave.NewAPI("profile"))).To(Succeed())
+			Expect(do(http.MethodGet, "http://api.example.com/users/1/profile").Body.String()).To(Equal("users"))
+		})
+		It("should restore the inherited handler when set to nil", func() {
+			h.SetNotFoundHandler(respond(http.StatusNotFound, "host"))
+			h.SetNotFoundHandler(nil)
+			Expect(do(http.MethodGet, "http://api.example.com/missing").Body.String()).To(Equal("server"))
+		})
+	})
+})
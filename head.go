@@ -0,0 +1,51 @@
+package resweave
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// headResponseWriter discards the body written by a GET handler serving a HEAD request, while preserving the headers
+// and status and setting the Content-Length the body would have had.
+type headResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	written int
+}
+
+func newHeadResponseWriter(w http.ResponseWriter) *headResponseWriter {
+	return &headResponseWriter{ResponseWriter: w}
+}
+
+// WriteHeader records the status, which is written by finish once the Content-Length is known.
+func (hw *headResponseWriter) WriteHeader(statusCode int) {
+	if hw.status == 0 {
+		hw.status = statusCode
+	}
+}
+
+func (hw *headResponseWriter) Write(b []byte) (int, error) {
+	if hw.status == 0 {
+		hw.status = http.StatusOK
+	}
+	hw.written += len(b)
+	return len(b), nil
+}
+
+// finish writes the recorded status, setting the Content-Length unless the handler has set it or the status has no
+// content (1xx, 204 and 304; RFC 9110 section 8.6).
+func (hw *headResponseWriter) finish() {
+	if hw.status == 0 {
+		hw.status = http.StatusOK
+	}
+	h := hw.Header()
+	if bodyAllowed(hw.status) && h.Get("Content-Length") == "" && h.Get("Transfer-Encoding") == "" {
+		h.Set("Content-Length", strconv.Itoa(hw.written))
+	}
+	hw.ResponseWriter.WriteHeader(hw.status)
+}
+
+// bodyAllowed returns false for the statuses which never have content, and so no Content-Length.
+func bodyAllowed(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+}
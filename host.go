@@ -2,10 +2,13 @@ package resweave
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
+	"sync/atomic"
 
 	"go.uber.org/zap"
 )
@@ -23,8 +26,47 @@ type Host interface {
 	// GetResource retrieves the top level resource identified by the provided name and sets found to true.
 	// If the resource is not able to be found at the top level, nil with an explanatory error will be returned.
 	GetResource(name ResourceName) (res Resource, found bool)
+	// RemoveResource removes the top level resource identified by the provided name, returning the removed resource.
+	// If no resource with the name exists, an error wrapping ErrResourceNotFound is returned.
+	RemoveResource(name ResourceName) (Resource, error)
+	// ReplaceResource replaces the top level resource with the same name as r, returning the replaced resource.
+	// Requests already being handled by the replaced resource complete against it; new requests are served by r.
+	// If r is nil, ErrNilResource is returned; if no resource with the name exists, an error wrapping
+	// ErrResourceNotFound is returned.
+	ReplaceResource(r Resource) (Resource, error)
+	// SetNotFoundHandler sets the function responding to requests for unknown resources on this Host, overriding
+	// any set on the Server. Resources may override it in turn. nil restores the inherited handler.
+	SetNotFoundHandler(f ResweaveFunc)
+	// SetMethodNotAllowedHandler sets the function responding to requests for actions without a handler on this
+	// Host's resources, overriding any set on the Server. nil restores the inherited handler.
+	SetMethodNotAllowedHandler(f ResweaveFunc)
+	// AddInterceptor adds an interceptor to the start of the Host's handling chain, as for Server.AddInterceptor.
+	// Host interceptors run after the Server's interceptors and before those of the resources.
+	// The Host is available to the interceptor via HostFromContext.
+	AddInterceptor(f Interceptor)
+	// SetCanonicalRedirect makes the Host redirect requests made under any other name (e.g. an alias) to its own
+	// name, keeping the scheme, port, path and query. code must be http.StatusMovedPermanently or
+	// http.StatusPermanentRedirect; 0 disables the redirect. Other codes return ErrInvalidRedirectCode.
+	// The redirect is not applied to the default Host or to Hosts named by a pattern.
+	SetCanonicalRedirect(code int) error
+	// Routes returns the routes served by the Host, walking every resource, sub-resource and child resource.
+	Routes() RouteTable
 	// Serve handles serving the resources under the Host.
 	Serve(w http.ResponseWriter, req *http.Request)
+	// SetCertificate sets the TLS certificate the Server presents when this Host is requested via SNI.
+	// Setting a certificate on any Host enables TLS on the Server.
+	SetCertificate(cert *tls.Certificate)
+	// SetCertificateFiles loads the PEM encoded certificate and key files to present for this Host via SNI.
+	// The files are reloaded when they change on disk. An error is returned if the files cannot be loaded.
+	SetCertificateFiles(certFile, keyFile string) error
+	// GetCertificate returns the certificate for this Host, or nil if no certificate has been set.
+	GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error)
+	// HasCertificate returns true if a certificate has been set for this Host.
+	HasCertificate() bool
+	// AddShutdownHook registers a hook to be run when the Server shuts down.
+	// Hooks on a Host are run after the shutdown hooks of its resources.
+	AddShutdownHook(hook ShutdownHook)
+	Shutdowner
 	LogHolder
 }
 
@@ -32,21 +74,50 @@ type Host interface {
 type HostName string
 
 // StripPort strips any port information from the provided HostName. e.g. localhost:8080 -> localhost
+// The brackets around IPv6 literals are also removed, e.g. [::1]:8080 -> ::1
 func (h HostName) StripPort() HostName {
-	return HostName(strings.Split(string(h), ":")[0])
+	if host, _, err := net.SplitHostPort(string(h)); err == nil {
+		return HostName(host)
+	}
+	return HostName(strings.TrimSuffix(strings.TrimPrefix(string(h), "["), "]"))
+}
+
+// Normalize returns the canonical form of the HostName used for matching Hosts: without a port, IPv6 brackets or
+// trailing dot, and in lower case. e.g. WWW.Example.COM.:8080 -> www.example.com
+func (h HostName) Normalize() HostName {
+	return HostName(strings.ToLower(strings.TrimSuffix(string(h.StripPort()), ".")))
+}
+
+// joinHostPort joins a host and an optional port, bracketing IPv6 literals as required in a URL.
+func joinHostPort(host string, port string) string {
+	if port == "" {
+		if strings.Contains(host, ":") {
+			return "[" + host + "]"
+		}
+		return host
+	}
+	return net.JoinHostPort(host, port)
 }
 
 // HostMap is a convenience alias to a map of HostNames to Hosts
 type HostMap map[HostName]Host
 
 type host struct {
-	name      HostName
-	resources ResourceMap
+	name         HostName
+	resources    routeTable[ResourceName, Resource]
+	shutdown     shutdownHooks
+	certificate  atomic.Pointer[certificateFunc]
+	redirect     atomic.Int32
+	interceptors interceptorChain
+	fallback     fallbackHandlers
 	LogHolder
 }
 
+// certificateFunc supplies the certificate for a TLS handshake; see tls.Config.GetCertificate.
+type certificateFunc func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+
 func newHost(name HostName) Host {
-	h := &host{name: name, resources: make(ResourceMap)}
+	h := &host{name: name}
 	h.LogHolder = NewLogholder(string(name.StripPort()), h.recurse)
 	return h
 }
@@ -56,7 +127,7 @@ func (h *host) Name() HostName {
 }
 
 func (h *host) TopLevelResourceCount() int {
-	return len(h.resources)
+	return h.resources.len()
 }
 
 func (h *host) AddResource(r Resource) error {
@@ -65,22 +136,130 @@ func (h *host) AddResource(r Resource) error {
 		return errors.New("cannot add a nil resource")
 	}
 
-	if _, found := h.resources[r.Name()]; found {
-		h.Infow("AddResource", "Name", r.Name(), "Exists?", found)
-		return fmt.Errorf(FmtResourceAlreadyExists, r.Name(), h.Name())
+	err := h.resources.update(func(resources map[ResourceName]Resource) error {
+		if _, found := resources[r.Name()]; found {
+			h.Infow("AddResource", "Name", r.Name(), "Exists?", found)
+			return fmt.Errorf(FmtResourceAlreadyExists, r.Name(), h.Name())
+		}
+		resources[r.Name()] = r
+		return nil
+	})
+	if err == nil {
+		h.Infow("AddResource", "Name", fmt.Sprintf("'%s'", r.Name()), "Added", true)
 	}
-	h.resources[r.Name()] = r
-	h.Infow("AddResource", "Name", fmt.Sprintf("'%s'", r.Name()), "Added", true)
-	return nil
+	return err
 }
 
 func (h *host) GetResource(name ResourceName) (res Resource, found bool) {
-	res, found = h.resources[name]
-	return
+	return h.resources.get(name)
+}
+
+func (h *host) RemoveResource(name ResourceName) (Resource, error) {
+	var res Resource
+	err := h.resources.update(func(resources map[ResourceName]Resource) error {
+		var found bool
+		if res, found = resources[name]; !found {
+			h.Infow("RemoveResource", "Name", name, "Exists?", found)
+			return fmt.Errorf(fmtResourceNotFoundOnHost, ErrResourceNotFound, name, h.Name())
+		}
+		delete(resources, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	h.Infow("RemoveResource", "Name", fmt.Sprintf("'%s'", name), "Removed", true)
+	return res, nil
+}
+
+func (h *host) ReplaceResource(r Resource) (Resource, error) {
+	if r == nil {
+		h.Infow("ReplaceResource", "Error", "resource was nil")
+		return nil, ErrNilResource
+	}
+	var old Resource
+	err := h.resources.update(func(resources map[ResourceName]Resource) error {
+		var found bool
+		if old, found = resources[r.Name()]; !found {
+			h.Infow("ReplaceResource", "Name", r.Name(), "Exists?", found)
+			return fmt.Errorf(fmtResourceNotFoundOnHost, ErrResourceNotFound, r.Name(), h.Name())
+		}
+		resources[r.Name()] = r
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	h.Infow("ReplaceResource", "Name", fmt.Sprintf("'%s'", r.Name()), "Replaced", true)
+	return old, nil
+}
+
+func (h *host) SetNotFoundHandler(f ResweaveFunc) {
+	h.fallback.setNotFound(f)
+}
+
+func (h *host) SetMethodNotAllowedHandler(f ResweaveFunc) {
+	h.fallback.setMethodNotAllowed(f)
+}
+
+func (h *host) AddInterceptor(f Interceptor) {
+	h.interceptors.add(f, http.HandlerFunc(h.serve))
+}
+
+func (h *host) SetCanonicalRedirect(code int) error {
+	switch code {
+	case 0, http.StatusMovedPermanently, http.StatusPermanentRedirect:
+		h.redirect.Store(int32(code))
+		return nil
+	default:
+		return fmt.Errorf("%w: %d", ErrInvalidRedirectCode, code)
+	}
+}
+
+// redirectToCanonical redirects the request to the Host's own name if it was made under a different name, returning
+// true if a redirect was written.
+func (h *host) redirectToCanonical(w http.ResponseWriter, req *http.Request) bool {
+	code := int(h.redirect.Load())
+	if code == 0 || h.Name() == defaultHostName || isHostPattern(h.Name()) {
+		return false
+	}
+	_, port, err := net.SplitHostPort(req.Host)
+	if err != nil {
+		port = ""
+	}
+	if HostName(req.Host).Normalize() == h.Name() {
+		return false
+	}
+	target := *req.URL
+	target.Scheme = "http"
+	if req.TLS != nil {
+		target.Scheme = "https"
+	}
+	target.Host = joinHostPort(string(h.Name()), port)
+	h.Infow("redirectToCanonical", "From", req.Host, "To", target.Host, "Code", code)
+	http.Redirect(w, req, target.String(), code)
+	return true
+}
+
+func (h *host) Routes() RouteTable {
+	rb := &routeBuilder{host: h.Name()}
+	for _, r := range sortedResources(h.resources.load()) {
+		rb.walk("", nil, r)
+	}
+	return rb.routes
 }
 
 func (h *host) Serve(w http.ResponseWriter, req *http.Request) {
 	h.Infow("serve", "Host Name", h.Name(), "Request URI", req.RequestURI)
+	if h.redirectToCanonical(w, req) {
+		return
+	}
+	req = req.WithContext(h.fallback.withContext(context.WithValue(req.Context(), KeyHost, Host(h))))
+	h.interceptors.handler(http.HandlerFunc(h.serve)).ServeHTTP(w, req)
+}
+
+// serve routes the request to the matching resource once it has passed through the Host's interceptors.
+func (h *host) serve(w http.ResponseWriter, req *http.Request) {
 	var reqPaths []ResourceName
 	if strings.HasSuffix(req.URL.Path, "/") {
 		reqPaths = ResourceNames(strings.Split(req.URL.Path[:len(req.URL.Path)-1], "/"))
@@ -98,14 +277,16 @@ func (h *host) Serve(w http.ResponseWriter, req *http.Request) {
 	if pathIdx >= len(reqPaths) {
 		pathIdx = 0
 	}
-	res, found := h.GetResource(reqPaths[pathIdx])
+	// Route against a single snapshot so a concurrent change cannot be observed part way through a request.
+	resources := h.resources.load()
+	res, found := resources[reqPaths[pathIdx]]
 	h.Infow("serve", "Request Path:", reqPaths[pathIdx], "Found?", found)
 	if !found {
 		pathIdx = 0
 		if !leadSlash {
 			reqPaths = append(ResourceNames([]string{""}), reqPaths...)
 		}
-		res, found = h.GetResource(ResourceName(""))
+		res, found = resources[ResourceName("")]
 	}
 	if found {
 		ctx = context.WithValue(ctx, KeyURISegments, reqPaths[pathIdx:])
@@ -113,11 +294,54 @@ func (h *host) Serve(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 	h.Infow("serve", "Hard Return Code", http.StatusNotFound)
-	w.WriteHeader(http.StatusNotFound)
+	notFound(ctx, w, req)
+}
+
+func (h *host) SetCertificate(cert *tls.Certificate) {
+	if cert == nil {
+		h.certificate.Store(nil)
+		return
+	}
+	var f certificateFunc = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return cert, nil
+	}
+	h.certificate.Store(&f)
+}
+
+func (h *host) SetCertificateFiles(certFile, keyFile string) error {
+	reloader, err := newCertReloader(certFile, keyFile, h)
+	if err != nil {
+		h.Errorw("SetCertificateFiles", "Certificate File", certFile, "Error", err)
+		return err
+	}
+	var f certificateFunc = reloader.GetCertificate
+	h.certificate.Store(&f)
+	return nil
+}
+
+func (h *host) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	f := h.certificate.Load()
+	if f == nil {
+		return nil, nil
+	}
+	return (*f)(hello)
+}
+
+func (h *host) HasCertificate() bool {
+	return h.certificate.Load() != nil
+}
+
+func (h *host) AddShutdownHook(hook ShutdownHook) {
+	h.shutdown.add(hook)
+}
+
+func (h *host) Shutdown(ctx context.Context) error {
+	h.Infow("Shutdown", "Host Name", h.Name())
+	return errors.Join(shutdownResources(ctx, h.resources.load()), h.shutdown.run(ctx))
 }
 
 func (h *host) recurse(logger *zap.SugaredLogger) {
-	for _, v := range h.resources {
+	for _, v := range h.resources.load() {
 		v.SetLogger(logger.Named(string(h.name)), true)
 	}
 }
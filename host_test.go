@@ -191,6 +191,48 @@ var _ = Describe("Host", func() {
 		})
 
 	})
+	Describe("Removing and Replacing Resources", func() {
+		var (
+			usersRes Resource
+		)
+		BeforeEach(func() {
+			usersRes = NewAPI("users")
+			Expect(caHost.AddResource(usersRes)).To(Succeed())
+		})
+		It("should be possible to remove a resource", func() {
+			removed, err := caHost.RemoveResource("users")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(removed).To(Equal(usersRes))
+			Expect(caHost.TopLevelResourceCount()).To(BeZero())
+			_, found := caHost.GetResource("users")
+			Expect(found).To(BeFalse())
+		})
+		It("should error when removing a resource which does not exist", func() {
+			removed, err := caHost.RemoveResource("other")
+			Expect(err).To(MatchError(ErrResourceNotFound))
+			Expect(removed).To(BeNil())
+			Expect(caHost.TopLevelResourceCount()).To(Equal(1))
+		})
+		It("should be possible to replace a resource and serve the replacement", func() {
+			replacement := NewAPI("users")
+			replacement.SetList(func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			})
+			old, err := caHost.ReplaceResource(replacement)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(old).To(Equal(usersRes))
+
+			recorder := httptest.NewRecorder()
+			caHost.Serve(recorder, httptest.NewRequest(http.MethodGet, "/users", nil))
+			Expect(recorder.Code).To(Equal(http.StatusTeapot))
+		})
+		It("should error when replacing a resource which does not exist", func() {
+			_, err := caHost.ReplaceResource(NewAPI("other"))
+			Expect(err).To(MatchError(ErrResourceNotFound))
+			_, err = caHost.ReplaceResource(nil)
+			Expect(err).To(MatchError(ErrNilResource))
+		})
+	})
 	Describe("HTML Usage", func() {
 		const (
 			htmlDir  = "test/html/"
@@ -317,11 +359,34 @@ var _ = Describe("Host", func() {
 			Expect(caHost.Logger()).To(BeNil())
 			caHost.SetLogger(l.Sugar(), false)
 			Expect(caHost.Logger()).ToNot(BeNil())
-			Expect(caHost.(*host).resources[""].Logger()).To(BeNil())
+			Expect(caHost.(*host).resources.load()[""].Logger()).To(BeNil())
 			caHost.SetLogger(nil, false)
 			Expect(caHost.Logger()).To(BeNil())
-			Expect(caHost.(*host).resources[""].Logger()).To(BeNil())
+			Expect(caHost.(*host).resources.load()[""].Logger()).To(BeNil())
 		})
 
 	})
+	Describe("Host Names", func() {
+		DescribeTable("StripPort should remove the port and IPv6 brackets",
+			func(name HostName, expected HostName) {
+				Expect(name.StripPort()).To(Equal(expected))
+			},
+			Entry("host only", HostName("localhost"), HostName("localhost")),
+			Entry("host and port", HostName("localhost:8080"), HostName("localhost")),
+			Entry("IPv4 and port", HostName("127.0.0.1:8080"), HostName("127.0.0.1")),
+			Entry("bracketed IPv6 and port", HostName("[::1]:8080"), HostName("::1")),
+			Entry("bracketed IPv6", HostName("[::1]"), HostName("::1")),
+			Entry("bare IPv6", HostName("2001:db8::1"), HostName("2001:db8::1")),
+			Entry("empty", HostName(""), HostName("")),
+		)
+		DescribeTable("Normalize should produce the canonical host name",
+			func(name HostName, expected HostName) {
+				Expect(name.Normalize()).To(Equal(expected))
+			},
+			Entry("lower case", HostName("Daniel-Taylor.CA"), HostName("daniel-taylor.ca")),
+			Entry("trailing dot", HostName("daniel-taylor.ca."), HostName("daniel-taylor.ca")),
+			Entry("trailing dot and port", HostName("Daniel-Taylor.ca.:443"), HostName("daniel-taylor.ca")),
+			Entry("IPv6", HostName("[2001:DB8::1]:8080"), HostName("2001:db8::1")),
+		)
+	})
 })
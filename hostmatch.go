@@ -0,0 +1,129 @@
+package resweave
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// KeyHostLabels is the context key for the host labels matched by a wildcard or regex Host; see GetHostLabels.
+	KeyHostLabels = Key("HOST_LABELS")
+
+	hostWildcardPrefix = "*."
+	hostRegexPrefix    = "~"
+	fmtInvalidHost     = "%w: '%s'"
+)
+
+var ErrInvalidHostPattern = errors.New("invalid host pattern")
+
+// hostPattern matches request host names against a wildcard or regex Host name.
+type hostPattern struct {
+	// name is the name the pattern was registered under; a Host's own name or an alias.
+	name HostName
+	host Host
+	// suffix is set for wildcard patterns, e.g. .app.example.com for *.app.example.com.
+	suffix string
+	// rxp is set for regex patterns.
+	rxp *regexp.Regexp
+}
+
+// newHostPattern returns the pattern for a wildcard (*.example.com) or regex (~pattern) Host name, or nil if the name
+// is an exact host name.
+func newHostPattern(name HostName, h Host) (*hostPattern, error) {
+	n := string(name)
+	switch {
+	case strings.HasPrefix(n, hostWildcardPrefix):
+		suffix := strings.ToLower(n[len(hostWildcardPrefix)-1:])
+		if len(suffix) < 2 || strings.Contains(suffix, "*") {
+			return nil, fmt.Errorf(fmtInvalidHost, ErrInvalidHostPattern, name)
+		}
+		return &hostPattern{name: name, host: h, suffix: suffix}, nil
+	case strings.HasPrefix(n, hostRegexPrefix):
+		rxp, err := regexp.Compile(fmt.Sprintf("^(?i:%s)$", n[len(hostRegexPrefix):]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: '%s': %w", ErrInvalidHostPattern, name, err)
+		}
+		return &hostPattern{name: name, host: h, rxp: rxp}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// isHostPattern returns true if the name is a wildcard or regex host pattern rather than an exact host name.
+func isHostPattern(name HostName) bool {
+	return strings.HasPrefix(string(name), hostWildcardPrefix) || strings.HasPrefix(string(name), hostRegexPrefix)
+}
+
+// normalizeHostName normalizes exact host names; patterns are left as provided.
+func normalizeHostName(name HostName) HostName {
+	if isHostPattern(name) {
+		return name
+	}
+	return name.Normalize()
+}
+
+// match returns the labels matched in the host name: the labels preceding the suffix of a wildcard pattern, or the
+// sub-matches of a regex pattern.
+func (p *hostPattern) match(name string) ([]string, bool) {
+	if p.rxp != nil {
+		m := p.rxp.FindStringSubmatch(name)
+		if m == nil {
+			return nil, false
+		}
+		return m[1:], true
+	}
+	prefix, found := strings.CutSuffix(strings.ToLower(name), p.suffix)
+	if !found || prefix == "" {
+		return nil, false
+	}
+	labels := strings.Split(prefix, ".")
+	if slices.Contains(labels, "") {
+		return nil, false
+	}
+	return labels, true
+}
+
+// sortHostPatterns orders wildcard patterns before regex patterns, with the longest (most specific) wildcard first.
+// Regex patterns keep the order they were added in.
+func sortHostPatterns(patterns []*hostPattern) {
+	slices.SortStableFunc(patterns, func(a, b *hostPattern) int {
+		switch {
+		case a.rxp == nil && b.rxp == nil:
+			return len(b.suffix) - len(a.suffix)
+		case a.rxp == nil:
+			return -1
+		case b.rxp == nil:
+			return 1
+		default:
+			return 0
+		}
+	})
+}
+
+// matchHost finds the Host for the request host name: an exact match takes precedence over wildcard patterns, which
+// take precedence over regex patterns. The matched labels are returned for pattern Hosts.
+func (s *server) matchHost(name HostName) (Host, []string, bool) {
+	if h, found := s.hosts.get(name); found {
+		return h, nil, true
+	}
+	if patterns := s.hostPatterns.Load(); patterns != nil {
+		for _, p := range *patterns {
+			if labels, found := p.match(string(name)); found {
+				return p.host, labels, true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// GetHostLabels returns the host labels matched for the request by a wildcard or regex Host.
+// For *.app.example.com and a request to acme.app.example.com the labels are [acme]; for a regex Host they are
+// the sub-matches of the expression. It returns nil for exact Hosts.
+func GetHostLabels(ctx context.Context) []string {
+	labels, _ := ctx.Value(KeyHostLabels).([]string)
+	return labels
+}
@@ -15,36 +15,49 @@ type HTMLResource interface {
 	Resource
 	BaseDir() string
 	FullPath() ResourceName
+	// AddInterceptor adds an interceptor to the start of the resource's handling chain, as for Server.AddInterceptor,
+	// e.g. to add caching headers. The resource is available to the interceptor via ResourceFromContext.
+	AddInterceptor(f Interceptor)
 }
 
 type htmlResource struct {
 	LogHolder
-	name    ResourceName
-	base    string
-	handler http.Handler
+	name         ResourceName
+	base         string
+	handler      http.Handler
+	interceptors interceptorChain
 }
 
 // NewHTML creates a new HTMLResource for use with a resweave Server
 func NewHTML(name ResourceName, baseDir string) HTMLResource {
 	// HTML resources never have sub resources; no recurser function necessary.
-	return &htmlResource{name: name, base: baseDir, LogHolder: NewLogholder(name.String(), nil)}
+	h := &htmlResource{name: name, base: baseDir, LogHolder: NewLogholder(name.String(), nil)}
+	// The handler is built up front, as assigning it on the request path would race between concurrent requests.
+	h.handler = http.StripPrefix(h.FullPath().String(), http.FileServer(http.Dir(h.base)))
+	return h
 }
 
 func (h *htmlResource) Name() ResourceName {
 	return h.name
 }
 
-func (h *htmlResource) HandleCall(_ context.Context, w http.ResponseWriter, req *http.Request) {
+func (h *htmlResource) HandleCall(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	req = req.WithContext(context.WithValue(ctx, KeyResource, Resource(h)))
+	h.interceptors.handler(http.HandlerFunc(h.serveHTTP)).ServeHTTP(w, req)
+}
+
+func (h *htmlResource) AddInterceptor(f Interceptor) {
+	h.interceptors.add(f, http.HandlerFunc(h.serveHTTP))
+}
+
+func (h *htmlResource) serveHTTP(w http.ResponseWriter, req *http.Request) {
 	f, err := os.Stat(h.base)
 	if err != nil {
 		h.Infow("Fetch", "Stat Base", h.base, "Error?", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(req.Context(), w, err)
 		return
 	}
 	h.Infow("Fetch", "Is directory?", f.IsDir())
-	if h.handler == nil {
-		h.handler = http.StripPrefix(h.FullPath().String(), http.FileServer(http.Dir(h.base)))
-	}
 	h.handler.ServeHTTP(w, req)
 }
 
@@ -0,0 +1,331 @@
+package resweave
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	headerIdempotencyKey      = "Idempotency-Key"
+	headerIdempotentReplayed  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	defaultIdempotencyKeyTTL  = 24 * time.Hour
+	defaultIdempotencyMaxBody = 1 << 20
+	idempotencyReplayedMarker = "true"
+)
+
+var (
+	// ErrIdempotencyKeyInUse is returned by an IdempotencyStore when a request with the key is still in progress; it
+	// wraps ErrConflict and so is answered with a 409.
+	ErrIdempotencyKeyInUse = fmt.Errorf("%w: idempotency key in use", ErrConflict)
+	// ErrIdempotencyKeyMismatch is returned by an IdempotencyStore when the key has been used for a different request;
+	// it is answered with a 422.
+	ErrIdempotencyKeyMismatch = errors.New("idempotency key used for a different request")
+	// ErrRequestTooLarge is written for a request with an Idempotency-Key whose body is larger than the limit set with
+	// WithIdempotencyMaxBody; it is answered with a 413.
+	ErrRequestTooLarge = errors.New("request body too large")
+)
+
+// IdempotencyOption configures optional behaviour of the Idempotency-Key handling enabled by
+// APIResource.SetIdempotency.
+type IdempotencyOption func(is *idempotencySettings)
+
+// IdempotencyScopeFunc returns the scope of the Idempotency-Key of a request, such as the authenticated caller.
+// Keys are only shared by requests with the same scope.
+type IdempotencyScopeFunc func(ctx context.Context, req *http.Request) string
+
+// idempotencySettings holds the IdempotencyStore of a resource and its IdempotencyOptions.
+type idempotencySettings struct {
+	store   IdempotencyStore
+	maxBody int64
+	scope   IdempotencyScopeFunc
+}
+
+// WithIdempotencyMaxBody sets the largest request body, in bytes, read to fingerprint a request with an
+// Idempotency-Key; larger bodies are answered with a 413. It defaults to 1 MiB, and n <= 0 restores the default.
+func WithIdempotencyMaxBody(n int64) IdempotencyOption {
+	return func(is *idempotencySettings) {
+		if n > 0 {
+			is.maxBody = n
+		}
+	}
+}
+
+// WithIdempotencyScope scopes Idempotency-Keys with f, so that requests with the same key but a different scope,
+// e.g. from different callers, are handled independently. Without it, keys are shared by every caller of the resource.
+func WithIdempotencyScope(f IdempotencyScopeFunc) IdempotencyOption {
+	return func(is *idempotencySettings) {
+		is.scope = f
+	}
+}
+
+// scopedKey returns the key passed to the IdempotencyStore for the request's Idempotency-Key.
+func (is *idempotencySettings) scopedKey(ctx context.Context, req *http.Request, key string) string {
+	if is.scope == nil {
+		return key
+	}
+	// The scope is length prefixed so that no scope and key pair can produce the key of another.
+	scope := is.scope(ctx, req)
+	return fmt.Sprintf("%d:%s:%s", len(scope), scope, key)
+}
+
+// IdempotentResponse is a response stored for an Idempotency-Key, replayed to retries of the request.
+type IdempotentResponse struct {
+	// Status is the HTTP status of the response.
+	Status int
+	// Header holds the headers set by the handler.
+	Header http.Header
+	// Body is the response body.
+	Body []byte
+}
+
+// IdempotencyStore stores the responses to requests with an Idempotency-Key header; see APIResource.SetIdempotency.
+// Implementations must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Begin reserves the key for the request with the fingerprint, returning nil if the request should be handled.
+	// If a response has been stored for the key and fingerprint it is returned instead. An error wrapping
+	// ErrIdempotencyKeyMismatch is returned if the key was reserved with a different fingerprint, and otherwise one
+	// wrapping ErrIdempotencyKeyInUse if the key is reserved without a stored response.
+	Begin(ctx context.Context, key string, fingerprint string) (*IdempotentResponse, error)
+	// Complete stores the response for the key reserved by Begin.
+	Complete(ctx context.Context, key string, resp IdempotentResponse) error
+	// Release removes the reservation of the key by Begin without storing a response, allowing the request to be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// idempotencyEntry is a key reserved in a memoryIdempotencyStore, with its response once complete.
+type idempotencyEntry struct {
+	fingerprint string
+	response    *IdempotentResponse
+	expires     time.Time
+}
+
+// memoryIdempotencyStore is the IdempotencyStore returned by NewMemoryIdempotencyStore.
+type memoryIdempotencyStore struct {
+	mtx       sync.Mutex
+	ttl       time.Duration
+	entries   map[string]idempotencyEntry
+	nextSweep time.Time
+}
+
+// NewMemoryIdempotencyStore returns an IdempotencyStore keeping keys in memory for the ttl after they are reserved,
+// or for 24 hours if ttl is not positive. Keys reserved by requests still in progress also expire after the ttl.
+func NewMemoryIdempotencyStore(ttl time.Duration) IdempotencyStore {
+	if ttl <= 0 {
+		ttl = defaultIdempotencyKeyTTL
+	}
+	return &memoryIdempotencyStore{ttl: ttl, entries: make(map[string]idempotencyEntry)}
+}
+
+func (s *memoryIdempotencyStore) Begin(_ context.Context, key string, fingerprint string) (*IdempotentResponse, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	now := time.Now()
+	s.sweep(now)
+	if e, found := s.entries[key]; found && now.Before(e.expires) {
+		switch {
+		case e.fingerprint != fingerprint:
+			return nil, fmt.Errorf("%w: '%s'", ErrIdempotencyKeyMismatch, key)
+		case e.response == nil:
+			return nil, fmt.Errorf("%w: '%s'", ErrIdempotencyKeyInUse, key)
+		default:
+			resp := e.response.clone()
+			return &resp, nil
+		}
+	}
+	s.entries[key] = idempotencyEntry{fingerprint: fingerprint, expires: now.Add(s.ttl)}
+	return nil, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(_ context.Context, key string, resp IdempotentResponse) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	e, found := s.entries[key]
+	if !found {
+		return fmt.Errorf("%w: idempotency key '%s'", ErrNotFound, key)
+	}
+	resp = resp.clone()
+	e.response = &resp
+	s.entries[key] = e
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(_ context.Context, key string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep removes expired entries, at most once per ttl.
+func (s *memoryIdempotencyStore) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	maps.DeleteFunc(s.entries, func(_ string, e idempotencyEntry) bool {
+		return !now.Before(e.expires)
+	})
+	s.nextSweep = now.Add(s.ttl)
+}
+
+func (r IdempotentResponse) clone() IdempotentResponse {
+	return IdempotentResponse{Status: r.Status, Header: r.Header.Clone(), Body: slices.Clone(r.Body)}
+}
+
+// replay writes the stored response, marking it with the Idempotent-Replayed header.
+func (r IdempotentResponse) replay(w http.ResponseWriter) {
+	for k, v := range r.Header {
+		w.Header()[k] = slices.Clone(v)
+	}
+	w.Header().Set(headerIdempotentReplayed, idempotencyReplayedMarker)
+	w.WriteHeader(r.Status)
+	_, _ = w.Write(r.Body)
+}
+
+// idempotencyRecorder records the status, the headers set by the handler and the body of a response as it is written.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	before http.Header
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func newIdempotencyRecorder(w http.ResponseWriter) *idempotencyRecorder {
+	return &idempotencyRecorder{ResponseWriter: w, before: w.Header().Clone()}
+}
+
+func (ir *idempotencyRecorder) WriteHeader(statusCode int) {
+	if ir.status == 0 {
+		ir.status = statusCode
+		ir.header = make(http.Header)
+		for k, v := range ir.Header() {
+			if !slices.Equal(ir.before[k], v) {
+				ir.header[k] = slices.Clone(v)
+			}
+		}
+	}
+	ir.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (ir *idempotencyRecorder) Write(b []byte) (int, error) {
+	if ir.status == 0 {
+		ir.WriteHeader(http.StatusOK)
+	}
+	ir.body.Write(b)
+	return ir.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (ir *idempotencyRecorder) Unwrap() http.ResponseWriter {
+	return ir.ResponseWriter
+}
+
+func (ir *idempotencyRecorder) response() IdempotentResponse {
+	if ir.status == 0 {
+		ir.WriteHeader(http.StatusOK)
+	}
+	return IdempotentResponse{Status: ir.status, Header: ir.header, Body: ir.body.Bytes()}
+}
+
+func (bar *BaseAPIRes) SetIdempotency(store IdempotencyStore, opts ...IdempotencyOption) {
+	if store == nil {
+		bar.idempotency.Store(nil)
+		return
+	}
+	is := &idempotencySettings{store: store, maxBody: defaultIdempotencyMaxBody}
+	for _, opt := range opts {
+		opt(is)
+	}
+	bar.idempotency.Store(is)
+}
+
+// requestFingerprint identifies a request by its method, URI and body, to detect an Idempotency-Key being reused.
+func requestFingerprint(req *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", req.Method, req.URL.RequestURI())
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotent serves the request, storing the response for its Idempotency-Key header, or replays the stored response
+// if it is a retry. Requests without the header, with a safe method, or for resources without an IdempotencyStore are
+// served as is. Responses with a 5xx status are not stored, so the request may be retried.
+func (bar *BaseAPIRes) idempotent(ctx context.Context, w http.ResponseWriter, req *http.Request, serve func(http.ResponseWriter, *http.Request)) {
+	const curMethod = "idempotent"
+	is := bar.idempotency.Load()
+	key := req.Header.Get(headerIdempotencyKey)
+	if is == nil || key == "" || isSafeMethod(req.Method) {
+		serve(w, req)
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		WriteError(ctx, w, fmt.Errorf("%w: %s header longer than %d characters", ErrInvalid, headerIdempotencyKey, maxIdempotencyKeyLength))
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, is.maxBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			WriteError(ctx, w, fmt.Errorf("%w: more than %d bytes", ErrRequestTooLarge, tooLarge.Limit))
+			return
+		}
+		WriteError(ctx, w, fmt.Errorf("%w: reading request body: %v", ErrInvalid, err))
+		return
+	}
+	req = req.Clone(ctx)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	key = is.scopedKey(ctx, req, key)
+
+	stored, err := is.store.Begin(ctx, key, requestFingerprint(req, body))
+	if err != nil {
+		WriteError(ctx, w, err)
+		return
+	}
+	if stored != nil {
+		bar.Debugw(curMethod, "Idempotency-Key", key, "Replayed", true)
+		stored.replay(w)
+		return
+	}
+
+	completed := false
+	defer func() {
+		if !completed {
+			// The handler panicked; release the key so the request may be retried.
+			if err := is.store.Release(ctx, key); err != nil {
+				bar.Errorw(curMethod, "Idempotency-Key", key, "Error", err)
+			}
+		}
+	}()
+	rec := newIdempotencyRecorder(w)
+	serve(rec, req)
+	completed = true
+	if resp := rec.response(); resp.Status < http.StatusInternalServerError {
+		err = is.store.Complete(ctx, key, resp)
+	} else {
+		err = is.store.Release(ctx, key)
+	}
+	if err != nil {
+		bar.Errorw(curMethod, "Idempotency-Key", key, "Error", err)
+	}
+}
+
+// isSafeMethod returns true for the HTTP methods which are safe, and so idempotent, by definition (RFC 9110 9.2.1).
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
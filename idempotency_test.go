@@ -0,0 +1,170 @@
+package resweave_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mortedecai/resweave"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Idempotency", func() {
+	var (
+		s       resweave.Server
+		orders  resweave.APIResource
+		created atomic.Int32
+		status  int
+		block   chan struct{}
+		entered chan struct{}
+	)
+	post := func(target string, key string, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		s.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		created.Store(0)
+		status = http.StatusCreated
+		block = nil
+		s = resweave.NewServer(0)
+		orders = resweave.NewAPI("orders")
+		orders.SetCreate(func(_ context.Context, w http.ResponseWriter, req *http.Request) {
+			if block != nil {
+				close(entered)
+				<-block
+			}
+			body, _ := io.ReadAll(req.Body)
+			n := created.Add(1)
+			w.Header().Set("Location", "/orders/"+strconv.Itoa(int(n)))
+			w.WriteHeader(status)
+			_, _ = w.Write(body)
+		})
+		orders.SetIdempotency(resweave.NewMemoryIdempotencyStore(time.Minute))
+		Expect(s.AddResource(orders)).To(Succeed())
+	})
+
+	It("should replay the stored response to a retry", func() {
+		first := post("/orders", "key-1", `{"item":"tea"}`)
+		Expect(first.Code).To(Equal(http.StatusCreated))
+		Expect(first.Header().Get("Idempotent-Replayed")).To(BeEmpty())
+
+		retry := post("/orders", "key-1", `{"item":"tea"}`)
+		Expect(retry.Code).To(Equal(http.StatusCreated))
+		Expect(retry.Header().Get("Location")).To(Equal("/orders/1"))
+		Expect(retry.Header().Get("Idempotent-Replayed")).To(Equal("true"))
+		Expect(retry.Body.String()).To(Equal(`{"item":"tea"}`))
+		Expect(created.Load()).To(BeEquivalentTo(1))
+
+		Expect(post("/orders", "key-2", `{"item":"tea"}`).Header().Get("Location")).To(Equal("/orders/2"))
+		Expect(post("/orders", "", `{"item":"tea"}`).Header().Get("Location")).To(Equal("/orders/3"))
+	})
+
+	It("should answer a key reused for a different request with a 422", func() {
+		post("/orders", "key-1", `{"item":"tea"}`)
+		rec := post("/orders", "key-1", `{"item":"coffee"}`)
+		Expect(rec.Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(rec.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+		Expect(created.Load()).To(BeEquivalentTo(1))
+	})
+
+	It("should answer a retry while the request is in progress with a 409", func() {
+		block, entered = make(chan struct{}), make(chan struct{})
+		done := make(chan *httptest.ResponseRecorder)
+		go func() {
+			defer GinkgoRecover()
+			done <- post("/orders", "key-1", `{}`)
+		}()
+		<-entered
+		Expect(post("/orders", "key-1", `{}`).Code).To(Equal(http.StatusConflict))
+		close(block)
+		Expect((<-done).Code).To(Equal(http.StatusCreated))
+		Expect(post("/orders", "key-1", `{}`).Header().Get("Idempotent-Replayed")).To(Equal("true"))
+	})
+
+	It("should not store server errors", func() {
+		status = http.StatusServiceUnavailable
+		Expect(post("/orders", "key-1", `{}`).Code).To(Equal(http.StatusServiceUnavailable))
+		status = http.StatusCreated
+		rec := post("/orders", "key-1", `{}`)
+		Expect(rec.Code).To(Equal(http.StatusCreated))
+		Expect(rec.Header().Get("Idempotent-Replayed")).To(BeEmpty())
+		Expect(created.Load()).To(BeEquivalentTo(2))
+	})
+
+	It("should apply to custom actions with an unsafe method", func() {
+		var charged atomic.Int32
+		_, err := orders.AddAction(resweave.CustomAction{
+			Name:   "charge",
+			Method: http.MethodPost,
+			Handler: func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+				charged.Add(1)
+				w.WriteHeader(http.StatusAccepted)
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(post("/orders/1:charge", "key-1", "").Code).To(Equal(http.StatusAccepted))
+		Expect(post("/orders/1:charge", "key-1", "").Code).To(Equal(http.StatusAccepted))
+		Expect(post("/orders/2:charge", "key-1", "").Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(charged.Load()).To(BeEquivalentTo(1))
+	})
+
+	It("should expire keys after the TTL", func() {
+		orders.SetIdempotency(resweave.NewMemoryIdempotencyStore(20 * time.Millisecond))
+		post("/orders", "key-1", `{}`)
+		Eventually(func() string {
+			return post("/orders", "key-1", `{}`).Header().Get("Idempotent-Replayed")
+		}).Should(BeEmpty())
+	})
+
+	It("should be disabled without a store", func() {
+		orders.SetIdempotency(nil)
+		post("/orders", "key-1", `{}`)
+		Expect(post("/orders", "key-1", `{}`).Header().Get("Location")).To(Equal("/orders/2"))
+	})
+
+	It("should answer bodies above the limit with a 413", func() {
+		orders.SetIdempotency(resweave.NewMemoryIdempotencyStore(time.Minute), resweave.WithIdempotencyMaxBody(8))
+		rec := post("/orders", "key-1", `{"item":"tea"}`)
+		Expect(rec.Code).To(Equal(http.StatusRequestEntityTooLarge))
+		Expect(rec.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+		Expect(post("/orders", "key-2", `{}`).Code).To(Equal(http.StatusCreated))
+		Expect(created.Load()).To(BeEquivalentTo(1))
+	})
+
+	It("should scope keys with the scope function", func() {
+		orders.SetIdempotency(resweave.NewMemoryIdempotencyStore(time.Minute), resweave.WithIdempotencyScope(
+			func(_ context.Context, req *http.Request) string {
+				return req.Header.Get("X-Caller")
+			}))
+		postAs := func(caller string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+			req.Header.Set("Idempotency-Key", "key-1")
+			req.Header.Set("X-Caller", caller)
+			s.ServeHTTP(recorder, req)
+			return recorder
+		}
+		Expect(postAs("alice", `{"item":"tea"}`).Code).To(Equal(http.StatusCreated))
+		Expect(postAs("bob", `{"item":"coffee"}`).Code).To(Equal(http.StatusCreated))
+		Expect(postAs("alice", `{"item":"tea"}`).Header().Get("Idempotent-Replayed")).To(Equal("true"))
+		Expect(postAs("bob", `{"item":"tea"}`).Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(created.Load()).To(BeEquivalentTo(2))
+	})
+
+	It("should reject overlong keys with a 400", func() {
+		Expect(post("/orders", strings.Repeat("k", 256), `{}`).Code).To(Equal(http.StatusBadRequest))
+		Expect(created.Load()).To(BeZero())
+	})
+})
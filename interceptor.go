@@ -0,0 +1,59 @@
+package resweave
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// KeyHost is the context key for the Host serving the request; see HostFromContext.
+	KeyHost = Key("HOST")
+	// KeyResource is the context key for the Resource handling the request; see ResourceFromContext.
+	KeyResource = Key("RESOURCE")
+)
+
+// interceptorChain holds the Interceptors added to a Server, Host or Resource.
+// The zero value is an empty chain ready for use.
+type interceptorChain struct {
+	mtx   sync.Mutex
+	chain atomic.Pointer[http.Handler]
+}
+
+// add wraps the current chain, or final if the chain is empty, with f, so f runs before any interceptor already added.
+// Additions are serialised; requests in flight keep the chain they started with.
+func (ic *interceptorChain) add(f Interceptor, final http.Handler) {
+	ic.mtx.Lock()
+	defer ic.mtx.Unlock()
+	next := ic.handler(final)
+	wrapped := f(next)
+	ic.chain.Store(&wrapped)
+}
+
+// handler returns the head of the chain, or final if no interceptors have been added.
+func (ic *interceptorChain) handler(final http.Handler) http.Handler {
+	if h := ic.chain.Load(); h != nil {
+		return *h
+	}
+	return final
+}
+
+// empty returns true if no interceptors have been added.
+func (ic *interceptorChain) empty() bool {
+	return ic.chain.Load() == nil
+}
+
+// HostFromContext returns the Host serving the request.
+func HostFromContext(ctx context.Context) (Host, bool) {
+	h, ok := ctx.Value(KeyHost).(Host)
+	return h, ok
+}
+
+// ResourceFromContext returns the Resource handling the request.
+// Within an Interceptor added to a Resource this is the Resource the Interceptor was added to, and within a
+// ResweaveFunc it is the Resource whose handler is being called.
+func ResourceFromContext(ctx context.Context) (Resource, bool) {
+	r, ok := ctx.Value(KeyResource).(Resource)
+	return r, ok
+}
@@ -0,0 +1,147 @@
+package resweave_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/mortedecai/resweave"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Interceptors", func() {
+	var (
+		s     resweave.Server
+		api   resweave.Host
+		admin resweave.APIResource
+		users resweave.APIResource
+		calls []string
+	)
+	// record returns an interceptor appending name to calls before passing the request on.
+	record := func(name string) resweave.Interceptor {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	ok := func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+		calls = append(calls, "handler")
+		w.WriteHeader(http.StatusOK)
+	}
+	get := func(target string) int {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+		return recorder.Code
+	}
+
+	BeforeEach(func() {
+		calls = nil
+		s = resweave.NewServer(0)
+		var err error
+		api, err = s.AddHost("api.example.com")
+		Expect(err).ToNot(HaveOccurred())
+
+		admin = resweave.NewAPI("admin")
+		admin.SetList(ok)
+		settings := resweave.NewAPI("settings")
+		settings.SetList(ok)
+		Expect(admin.AddResource(settings)).To(Succeed())
+		users = resweave.NewAPI("users")
+		users.SetList(ok)
+		Expect(api.AddResource(admin)).To(Succeed())
+		Expect(api.AddResource(users)).To(Succeed())
+		Expect(s.AddResource(resweave.NewHTML("static", "."))).To(Succeed())
+	})
+
+	It("should run server, host and resource interceptors in order", func() {
+		s.AddInterceptor(record("server"))
+		api.AddInterceptor(record("host 1"))
+		api.AddInterceptor(record("host 2"))
+		admin.AddInterceptor(record("admin"))
+
+		Expect(get("http://api.example.com/admin")).To(Equal(http.StatusOK))
+		Expect(calls).To(Equal([]string{"server", "host 2", "host 1", "admin", "handler"}))
+	})
+
+	It("should only apply resource interceptors to the resource and its sub-resources", func() {
+		admin.AddInterceptor(record("admin"))
+
+		Expect(get("http://api.example.com/users")).To(Equal(http.StatusOK))
+		Expect(calls).To(Equal([]string{"handler"}))
+
+		calls = nil
+		Expect(get("http://api.example.com/admin/settings")).To(Equal(http.StatusOK))
+		Expect(calls).To(Equal([]string{"admin", "handler"}))
+	})
+
+	It("should only apply host interceptors to the host", func() {
+		api.AddInterceptor(record("host"))
+		Expect(get("http://www.example.com/static/")).To(Equal(http.StatusOK))
+		Expect(calls).To(BeEmpty())
+	})
+
+	It("should be possible for an interceptor to stop the request", func() {
+		admin.AddInterceptor(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+			})
+		})
+		Expect(get("http://api.example.com/admin/settings")).To(Equal(http.StatusUnauthorized))
+		Expect(calls).To(BeEmpty())
+	})
+
+	It("should pass context values set by an interceptor to the handler", func() {
+		type userKey struct{}
+		admin.AddInterceptor(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, "alice")))
+			})
+		})
+		var user any
+		admin.SetList(func(ctx context.Context, w http.ResponseWriter, _ *http.Request) {
+			user = ctx.Value(userKey{})
+		})
+		Expect(get("http://api.example.com/admin")).To(Equal(http.StatusOK))
+		Expect(user).To(Equal("alice"))
+	})
+
+	It("should expose the host and resource an interceptor is attached to", func() {
+		var names []string
+		see := func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				h, found := resweave.HostFromContext(r.Context())
+				Expect(found).To(BeTrue())
+				name := string(h.Name())
+				if res, found := resweave.ResourceFromContext(r.Context()); found {
+					name += "/" + res.Name().String()
+				}
+				names = append(names, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+		api.AddInterceptor(see)
+		admin.AddInterceptor(see)
+		Expect(get("http://api.example.com/admin/settings")).To(Equal(http.StatusOK))
+		Expect(names).To(Equal([]string{"api.example.com", "api.example.com/admin"}))
+	})
+
+	It("should support interceptors on HTML resources", func() {
+		res, found := s.GetResource("static")
+		Expect(found).To(BeTrue())
+		res.(resweave.HTMLResource).AddInterceptor(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Cache-Control", "max-age=3600")
+				next.ServeHTTP(w, r)
+			})
+		})
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/static/", nil))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Cache-Control")).To(Equal("max-age=3600"))
+		Expect(strings.ToLower(recorder.Body.String())).To(ContainSubstring("<a href="))
+	})
+})
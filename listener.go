@@ -0,0 +1,214 @@
+package resweave
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+)
+
+const (
+	// listenFDsStart is the first file descriptor passed by systemd socket activation (SD_LISTEN_FDS_START).
+	listenFDsStart = 3
+
+	envListenPID = "LISTEN_PID"
+	envListenFDs = "LISTEN_FDS"
+)
+
+var (
+	// ErrNoSystemdListeners is returned when WithSystemdListeners is used but the process was not socket activated.
+	ErrNoSystemdListeners = errors.New("no systemd socket activation file descriptors were passed to this process")
+)
+
+// ListenFunc opens one or more net.Listeners for the Server to accept connections on.
+type ListenFunc func() ([]net.Listener, error)
+
+// WithListenFunc adds a custom ListenFunc to the Server.
+// When any listener option is provided, the Server only listens on the configured listeners and not on the port
+// provided to NewServer.
+func WithListenFunc(f ListenFunc) ServerOption {
+	return func(s *server) {
+		s.listenFuncs = append(s.listenFuncs, f)
+	}
+}
+
+// WithAddress listens on the provided TCP address, e.g. "127.0.0.1:8080", "[::1]:8080" or "localhost:0".
+// It may be used multiple times to listen on several addresses at once.
+func WithAddress(addr string) ServerOption {
+	return WithListenFunc(func() ([]net.Listener, error) {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{l}, nil
+	})
+}
+
+// WithUnixSocket listens on a Unix domain socket at the provided path.
+// A stale socket left behind at the path by a previous run is removed before listening.
+func WithUnixSocket(path string) ServerOption {
+	return WithListenFunc(func() ([]net.Listener, error) {
+		if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+		}
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{l}, nil
+	})
+}
+
+// WithListener serves on a listener which has already been opened by the caller.
+// The Server takes ownership of the listener and closes it on shutdown.
+func WithListener(l net.Listener) ServerOption {
+	return WithListenFunc(func() ([]net.Listener, error) {
+		return []net.Listener{l}, nil
+	})
+}
+
+// WithFileDescriptor listens on an inherited, already listening, socket file descriptor.
+func WithFileDescriptor(fd uintptr) ServerOption {
+	return WithListenFunc(func() ([]net.Listener, error) {
+		l, err := fileListener(fd)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{l}, nil
+	})
+}
+
+// WithSystemdListeners listens on every socket passed to the process through systemd socket activation
+// (the LISTEN_PID and LISTEN_FDS environment variables).
+// Running the Server returns ErrNoSystemdListeners if the process was not socket activated.
+func WithSystemdListeners() ServerOption {
+	return WithListenFunc(func() ([]net.Listener, error) {
+		fds, err := systemdFDs()
+		if err != nil {
+			return nil, err
+		}
+		listeners := make([]net.Listener, 0, len(fds))
+		for _, fd := range fds {
+			l, err := fileListener(fd)
+			if err != nil {
+				closeListeners(listeners)
+				return nil, err
+			}
+			listeners = append(listeners, l)
+		}
+		return listeners, nil
+	})
+}
+
+// systemdFDs returns the file descriptors passed by systemd socket activation, as described in sd_listen_fds(3).
+func systemdFDs() ([]uintptr, error) {
+	pid, err := strconv.Atoi(os.Getenv(envListenPID))
+	if err != nil || pid != os.Getpid() {
+		return nil, ErrNoSystemdListeners
+	}
+	count, err := strconv.Atoi(os.Getenv(envListenFDs))
+	if err != nil || count < 1 {
+		return nil, ErrNoSystemdListeners
+	}
+	fds := make([]uintptr, count)
+	for i := range fds {
+		fds[i] = uintptr(listenFDsStart + i)
+	}
+	return fds, nil
+}
+
+func fileListener(fd uintptr) (net.Listener, error) {
+	f := os.NewFile(fd, fmt.Sprintf("fd-%d", fd))
+	if f == nil {
+		return nil, fmt.Errorf("invalid file descriptor %d", fd)
+	}
+	// net.FileListener duplicates the descriptor, so the original is closed either way.
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+func closeListeners(listeners []net.Listener) {
+	for _, l := range listeners {
+		_ = l.Close()
+	}
+}
+
+// openListeners opens all configured listeners, or a TCP listener on the Server port if none have been configured.
+// On error, any listeners which were already opened are closed.
+func (s *server) openListeners() ([]net.Listener, error) {
+	funcs := s.listenFuncs
+	if len(funcs) == 0 {
+		funcs = []ListenFunc{func() ([]net.Listener, error) {
+			l, err := net.Listen("tcp", s.getRunAddr())
+			if err != nil {
+				return nil, err
+			}
+			return []net.Listener{l}, nil
+		}}
+	}
+	var listeners []net.Listener
+	for _, f := range funcs {
+		ls, err := f()
+		if err != nil {
+			closeListeners(listeners)
+			return nil, err
+		}
+		listeners = append(listeners, ls...)
+	}
+	if s.httpOpts.maxConnections > 0 {
+		sem := make(chan struct{}, s.httpOpts.maxConnections)
+		for i, l := range listeners {
+			listeners[i] = newLimitListener(l, sem)
+		}
+	}
+	return listeners, nil
+}
+
+// limitListener limits the number of open connections accepted, sharing its semaphore with the other listeners of
+// the Server so the limit applies across all of them.
+type limitListener struct {
+	net.Listener
+	sem       chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newLimitListener(l net.Listener, sem chan struct{}) *limitListener {
+	return &limitListener{Listener: l, sem: sem, done: make(chan struct{})}
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	select {
+	case l.sem <- struct{}{}:
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		<-l.sem
+		return nil, err
+	}
+	return &limitConn{Conn: conn, release: func() { <-l.sem }}, nil
+}
+
+func (l *limitListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+// limitConn releases its slot in the limitListener semaphore when closed.
+type limitConn struct {
+	net.Conn
+	release   func()
+	closeOnce sync.Once
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(c.release)
+	return err
+}
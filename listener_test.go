@@ -0,0 +1,136 @@
+package resweave
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Listeners", func() {
+	var (
+		s      Server
+		cancel context.CancelFunc
+		errCh  chan error
+	)
+	newServer := func(port int, opts ...ServerOption) Server {
+		srv := NewServer(port, opts...)
+		res := NewAPI("ping")
+		res.SetList(func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("pong"))
+		})
+		Expect(srv.AddResource(res)).To(Succeed())
+		return srv
+	}
+	run := func(count int) {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		errCh = make(chan error, 1)
+		go func() {
+			errCh <- s.RunContext(ctx)
+		}()
+		Eventually(s.Addrs).Should(HaveLen(count))
+	}
+	get := func(client *http.Client, url string) string {
+		resp, err := client.Get(url)
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		return string(data)
+	}
+	AfterEach(func() {
+		if cancel != nil {
+			cancel()
+			Eventually(errCh).Should(Receive(BeNil()))
+			cancel = nil
+		}
+	})
+
+	It("should report the bound port when port 0 is used", func() {
+		s = newServer(0)
+		Expect(s.Port()).To(BeZero())
+		run(1)
+		Expect(s.Port()).ToNot(BeZero())
+		Expect(get(http.DefaultClient, fmt.Sprintf("http://127.0.0.1:%d/ping", s.Port()))).To(Equal("pong"))
+	})
+	It("should listen on several addresses at once", func() {
+		s = newServer(0, WithAddress("127.0.0.1:0"), WithAddress("127.0.0.1:0"))
+		run(2)
+		for _, addr := range s.Addrs() {
+			Expect(addr.(*net.TCPAddr).IP.String()).To(Equal("127.0.0.1"))
+			Expect(get(http.DefaultClient, fmt.Sprintf("http://%s/ping", addr))).To(Equal("pong"))
+		}
+		Expect(s.Port()).To(Equal(s.Addrs()[0].(*net.TCPAddr).Port))
+	})
+	It("should listen on a Unix domain socket", func() {
+		dir, err := os.MkdirTemp("", "resweave")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		sock := filepath.Join(dir, "resweave.sock")
+		s = newServer(8080, WithUnixSocket(sock))
+		run(1)
+		Expect(s.Addrs()[0].Network()).To(Equal("unix"))
+		Expect(s.Port()).To(Equal(8080))
+
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+			},
+		}}
+		Expect(get(client, "http://unix/ping")).To(Equal("pong"))
+	})
+	It("should serve on a pre-opened listener", func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		s = newServer(0, WithListener(l))
+		run(1)
+		Expect(s.Port()).To(Equal(l.Addr().(*net.TCPAddr).Port))
+		Expect(get(http.DefaultClient, fmt.Sprintf("http://%s/ping", l.Addr()))).To(Equal("pong"))
+	})
+	It("should serve on an inherited file descriptor", func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer l.Close()
+		f, err := l.(*net.TCPListener).File()
+		Expect(err).ToNot(HaveOccurred())
+		defer f.Close()
+		fd, err := syscall.Dup(int(f.Fd()))
+		Expect(err).ToNot(HaveOccurred())
+
+		s = newServer(0, WithFileDescriptor(uintptr(fd)))
+		run(1)
+		Expect(get(http.DefaultClient, fmt.Sprintf("http://%s/ping", l.Addr()))).To(Equal("pong"))
+	})
+	It("should fail to run if the process was not socket activated", func() {
+		GinkgoT().Setenv(envListenPID, "")
+		s = newServer(0, WithSystemdListeners())
+		Expect(s.RunContext(context.Background())).To(MatchError(ErrNoSystemdListeners))
+	})
+	It("should not use systemd file descriptors meant for another process", func() {
+		GinkgoT().Setenv(envListenPID, fmt.Sprint(os.Getpid()+1))
+		GinkgoT().Setenv(envListenFDs, "1")
+		_, err := systemdFDs()
+		Expect(err).To(MatchError(ErrNoSystemdListeners))
+	})
+	It("should find the systemd file descriptors for this process", func() {
+		GinkgoT().Setenv(envListenPID, fmt.Sprint(os.Getpid()))
+		GinkgoT().Setenv(envListenFDs, "2")
+		Expect(systemdFDs()).To(Equal([]uintptr{3, 4}))
+	})
+	It("should close already opened listeners if a later one fails", func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer l.Close()
+		s = newServer(0, WithAddress("127.0.0.1:0"), WithAddress(l.Addr().String()))
+		Expect(s.RunContext(context.Background())).To(HaveOccurred())
+		Expect(s.Addrs()).To(BeEmpty())
+	})
+})
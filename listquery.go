@@ -0,0 +1,257 @@
+package resweave
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// KeyListQuery is the context key for the ListQuery of a List request; see ListQueryFromContext.
+	KeyListQuery = Key("LIST_QUERY")
+
+	queryLimit        = "limit"
+	queryOffset       = "offset"
+	queryCursor       = "cursor"
+	querySort         = "sort"
+	queryFilterPrefix = "filter["
+	queryFilterSuffix = "]"
+	sortDescending    = "-"
+	headerLink        = "Link"
+	headerTotalCount  = "X-Total-Count"
+)
+
+// ErrInvalidListQuery wraps errors in the query string of a List request; it wraps ErrInvalid and so is answered with
+// a 400.
+var ErrInvalidListQuery = fmt.Errorf("%w: list query", ErrInvalid)
+
+// ErrInvalidListOptions is returned by SetListOptions for negative limits or a DefaultLimit above the MaxLimit.
+var ErrInvalidListOptions = errors.New("invalid list options")
+
+// ListOptions declares the list query a resource accepts; see APIResource.SetListOptions.
+type ListOptions struct {
+	// DefaultLimit is the limit used when the request has none; if 0, the MaxLimit, or no limit without one.
+	DefaultLimit int
+	// MaxLimit is the largest limit accepted; 0 for no maximum.
+	MaxLimit int
+	// SortFields are the fields which may be sorted on.
+	SortFields []string
+	// FilterFields are the fields which may be filtered on.
+	FilterFields []string
+	// TotalCount enables the X-Total-Count header written by WritePageHeaders.
+	TotalCount bool
+}
+
+// SortField is a field of a sort order.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ListQuery is the query of a List request:
+//
+//	?limit=20&offset=40                   Limit and Offset
+//	?limit=20&cursor=<token>              Limit and an opaque Cursor; see EncodeCursor
+//	?sort=-created,name                   Sort, descending if prefixed with '-'
+//	?filter[status]=open&filter[tag]=a,b  Filters, matching any of the comma separated values
+type ListQuery struct {
+	Limit   int
+	Offset  int
+	Cursor  string
+	Sort    []SortField
+	Filters map[string][]string
+
+	options ListOptions
+}
+
+// ListQueryFromContext returns the ListQuery of the List request. It is only present for resources which have
+// ListOptions set.
+func ListQueryFromContext(ctx context.Context) (ListQuery, bool) {
+	q, ok := ctx.Value(KeyListQuery).(ListQuery)
+	return q, ok
+}
+
+func (bar *BaseAPIRes) SetListOptions(opts ListOptions) error {
+	if err := opts.validate(); err != nil {
+		bar.Infow("SetListOptions", "Error", err)
+		return err
+	}
+	bar.listOptions.Store(&opts)
+	return nil
+}
+
+func (opts ListOptions) validate() error {
+	switch {
+	case opts.DefaultLimit < 0 || opts.MaxLimit < 0:
+		return fmt.Errorf("%w: negative limit", ErrInvalidListOptions)
+	case opts.MaxLimit > 0 && opts.DefaultLimit > opts.MaxLimit:
+		return fmt.Errorf("%w: default limit %d above max limit %d", ErrInvalidListOptions, opts.DefaultLimit, opts.MaxLimit)
+	}
+	return nil
+}
+
+// withListQuery parses and validates the query of a List request if the resource has ListOptions set.
+func (bar *BaseAPIRes) withListQuery(ctx context.Context, req *http.Request) (context.Context, error) {
+	opts := bar.listOptions.Load()
+	if opts == nil {
+		return ctx, nil
+	}
+	q, err := parseListQuery(req.URL.Query(), *opts)
+	if err != nil {
+		return ctx, err
+	}
+	return context.WithValue(ctx, KeyListQuery, q), nil
+}
+
+func invalidListQuery(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidListQuery, fmt.Sprintf(format, args...))
+}
+
+func parseListQuery(values url.Values, opts ListOptions) (ListQuery, error) {
+	q := ListQuery{Limit: opts.DefaultLimit, Cursor: values.Get(queryCursor), options: opts}
+	if q.Limit == 0 {
+		// Leaving the limit out must not bypass the maximum.
+		q.Limit = opts.MaxLimit
+	}
+	if v := values.Get(queryLimit); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return q, invalidListQuery("limit '%s' must be a positive integer", v)
+		}
+		q.Limit = limit
+	}
+	if opts.MaxLimit > 0 && q.Limit > opts.MaxLimit {
+		return q, invalidListQuery("limit %d exceeds the maximum of %d", q.Limit, opts.MaxLimit)
+	}
+	if v := values.Get(queryOffset); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return q, invalidListQuery("offset '%s' must be a non-negative integer", v)
+		}
+		q.Offset = offset
+	}
+	if q.Offset > 0 && q.Cursor != "" {
+		return q, invalidListQuery("offset and cursor cannot be combined")
+	}
+	if v := values.Get(querySort); v != "" {
+		for field := range strings.SplitSeq(v, ",") {
+			sf := SortField{Field: strings.TrimPrefix(field, sortDescending), Desc: strings.HasPrefix(field, sortDescending)}
+			if !slices.Contains(opts.SortFields, sf.Field) {
+				return q, invalidListQuery("cannot sort on '%s'", sf.Field)
+			}
+			q.Sort = append(q.Sort, sf)
+		}
+	}
+	for key, vals := range values {
+		field, found := strings.CutPrefix(key, queryFilterPrefix)
+		if !found {
+			continue
+		}
+		if field, found = strings.CutSuffix(field, queryFilterSuffix); !found || !slices.Contains(opts.FilterFields, field) {
+			return q, invalidListQuery("cannot filter on '%s'", key)
+		}
+		if q.Filters == nil {
+			q.Filters = make(map[string][]string)
+		}
+		for _, v := range vals {
+			q.Filters[field] = append(q.Filters[field], strings.Split(v, ",")...)
+		}
+	}
+	return q, nil
+}
+
+// EncodeCursor encodes v as an opaque cursor token for Page.NextCursor or Page.PrevCursor.
+func EncodeCursor(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor decodes a cursor token created by EncodeCursor into v.
+// If the token is invalid an error wrapping ErrInvalidListQuery is returned.
+func DecodeCursor(token string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		err = json.Unmarshal(data, v)
+	}
+	if err != nil {
+		return invalidListQuery("cursor: %v", err)
+	}
+	return nil
+}
+
+// Page describes the page of items returned for a List request; see WritePageHeaders.
+type Page struct {
+	// Total is the number of items matching the query, written as X-Total-Count if ListOptions.TotalCount is set.
+	// With offset paging, there is a next page if Offset + Limit is less than Total.
+	Total int
+	// More indicates there is a next page with offset paging, when Total is not known.
+	More bool
+	// NextCursor and PrevCursor are the cursors of the next and previous pages with cursor paging, if any.
+	NextCursor string
+	PrevCursor string
+}
+
+// WritePageHeaders writes the Link header (RFC 8288) with the next and prev pages and, if enabled, the X-Total-Count
+// header for a List request. It must be called before the response status is written, and does nothing for resources
+// without ListOptions.
+//
+// With offset paging the links are the request URL with an updated offset; with cursor paging they are the request
+// URL with the cursor replaced by the NextCursor or PrevCursor. The path of the links is that of the RequestURI, as
+// sent by the client, so they remain valid when the Server is mounted under a prefix, e.g. with http.StripPrefix.
+func WritePageHeaders(ctx context.Context, w http.ResponseWriter, req *http.Request, page Page) {
+	q, ok := ListQueryFromContext(ctx)
+	if !ok {
+		return
+	}
+	if q.options.TotalCount {
+		w.Header().Set(headerTotalCount, strconv.Itoa(page.Total))
+	}
+	base := requestPath(req)
+	link := func(rel string, set map[string]string) {
+		values := req.URL.Query()
+		for k, v := range set {
+			if v == "" {
+				values.Del(k)
+				continue
+			}
+			values.Set(k, v)
+		}
+		u := url.URL{Path: base.Path, RawPath: base.RawPath, RawQuery: values.Encode()}
+		w.Header().Add(headerLink, fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel))
+	}
+	switch {
+	case q.Cursor != "" || page.NextCursor != "" || page.PrevCursor != "":
+		if page.NextCursor != "" {
+			link("next", map[string]string{queryCursor: page.NextCursor, queryOffset: ""})
+		}
+		if page.PrevCursor != "" {
+			link("prev", map[string]string{queryCursor: page.PrevCursor, queryOffset: ""})
+		}
+	case q.Limit > 0:
+		if page.More || q.Offset+q.Limit < page.Total {
+			link("next", map[string]string{queryOffset: strconv.Itoa(q.Offset + q.Limit), queryLimit: strconv.Itoa(q.Limit)})
+		}
+		if q.Offset > 0 {
+			link("prev", map[string]string{queryOffset: strconv.Itoa(max(q.Offset-q.Limit, 0)), queryLimit: strconv.Itoa(q.Limit)})
+		}
+	}
+}
+
+// requestPath returns the path of the request as sent by the client, falling back to the URL path for requests
+// without a RequestURI, such as those created for a client.
+func requestPath(req *http.Request) *url.URL {
+	if u, err := url.ParseRequestURI(req.RequestURI); err == nil && u.Path != "" {
+		return u
+	}
+	return req.URL
+}
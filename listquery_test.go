@@ -0,0 +1,149 @@
+package resweave_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/mortedecai/resweave"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("List Queries", func() {
+	var (
+		s     resweave.Server
+		items resweave.APIResource
+		query resweave.ListQuery
+		found bool
+		page  resweave.Page
+	)
+	list := func(target string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+		return recorder
+	}
+
+	BeforeEach(func() {
+		query, found, page = resweave.ListQuery{}, false, resweave.Page{}
+		s = resweave.NewServer(0)
+		items = resweave.NewAPI("items")
+		items.SetList(func(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+			query, found = resweave.ListQueryFromContext(ctx)
+			resweave.WritePageHeaders(ctx, w, req, page)
+			w.WriteHeader(http.StatusOK)
+		})
+		Expect(items.SetListOptions(resweave.ListOptions{
+			DefaultLimit: 10,
+			MaxLimit:     50,
+			SortFields:   []string{"created", "name"},
+			FilterFields: []string{"status", "tag"},
+			TotalCount:   true,
+		})).To(Succeed())
+		Expect(s.AddResource(items)).To(Succeed())
+	})
+
+	It("should parse the query into the context", func() {
+		Expect(list("/items?limit=20&offset=40&sort=-created,name&filter[status]=open&filter[tag]=a,b").Code).To(Equal(http.StatusOK))
+		Expect(found).To(BeTrue())
+		Expect(query.Limit).To(Equal(20))
+		Expect(query.Offset).To(Equal(40))
+		Expect(query.Sort).To(Equal([]resweave.SortField{{Field: "created", Desc: true}, {Field: "name"}}))
+		Expect(query.Filters).To(Equal(map[string][]string{"status": {"open"}, "tag": {"a", "b"}}))
+	})
+
+	It("should apply the default limit", func() {
+		list("/items")
+		Expect(query.Limit).To(Equal(10))
+		Expect(query.Offset).To(BeZero())
+		Expect(query.Sort).To(BeEmpty())
+		Expect(query.Filters).To(BeEmpty())
+	})
+
+	DescribeTable("should reject invalid queries with a 400 problem",
+		func(target string) {
+			rec := list(target)
+			Expect(rec.Code).To(Equal(http.StatusBadRequest))
+			Expect(rec.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+			Expect(found).To(BeFalse())
+		},
+		Entry("non-numeric limit", "/items?limit=ten"),
+		Entry("zero limit", "/items?limit=0"),
+		Entry("limit above the maximum", "/items?limit=51"),
+		Entry("negative offset", "/items?offset=-1"),
+		Entry("offset with a cursor", "/items?offset=10&cursor=abc"),
+		Entry("undeclared sort field", "/items?sort=price"),
+		Entry("undeclared filter field", "/items?filter[price]=1"),
+		Entry("malformed filter", "/items?filter[status=open"),
+	)
+
+	It("should not parse the query for resources without list options", func() {
+		other := resweave.NewAPI("other")
+		other.SetList(func(ctx context.Context, w http.ResponseWriter, _ *http.Request) {
+			query, found = resweave.ListQueryFromContext(ctx)
+		})
+		Expect(s.AddResource(other)).To(Succeed())
+		Expect(list("/other?limit=ten").Code).To(Equal(http.StatusOK))
+		Expect(found).To(BeFalse())
+	})
+
+	It("should write offset Link headers and the total count", func() {
+		page = resweave.Page{Total: 100}
+		rec := list("/items?limit=20&offset=40&sort=name")
+		Expect(rec.Header().Get("X-Total-Count")).To(Equal("100"))
+		Expect(rec.Header().Values("Link")).To(Equal([]string{
+			`</items?limit=20&offset=60&sort=name>; rel="next"`,
+			`</items?limit=20&offset=20&sort=name>; rel="prev"`,
+		}))
+
+		rec = list("/items?limit=20&offset=80")
+		Expect(rec.Header().Values("Link")).To(Equal([]string{`</items?limit=20&offset=60>; rel="prev"`}))
+
+		page = resweave.Page{More: true}
+		rec = list("/items")
+		Expect(rec.Header().Values("Link")).To(Equal([]string{`</items?limit=10&offset=10>; rel="next"`}))
+	})
+
+	It("should build Link headers from the path the client requested", func() {
+		page = resweave.Page{More: true}
+		recorder := httptest.NewRecorder()
+		http.StripPrefix("/api", s).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/items?limit=5", nil))
+		Expect(recorder.Header().Values("Link")).To(Equal([]string{`</api/items?limit=5&offset=5>; rel="next"`}))
+	})
+
+	It("should reject a default limit above the max limit", func() {
+		Expect(items.SetListOptions(resweave.ListOptions{DefaultLimit: 100, MaxLimit: 50})).To(MatchError(resweave.ErrInvalidListOptions))
+		Expect(items.SetListOptions(resweave.ListOptions{DefaultLimit: -1})).To(MatchError(resweave.ErrInvalidListOptions))
+		list("/items")
+		Expect(query.Limit).To(Equal(10))
+		Expect(items.SetListOptions(resweave.ListOptions{DefaultLimit: 100})).To(Succeed())
+	})
+
+	It("should default to the max limit without a default limit", func() {
+		Expect(items.SetListOptions(resweave.ListOptions{MaxLimit: 50})).To(Succeed())
+		Expect(list("/items").Code).To(Equal(http.StatusOK))
+		Expect(query.Limit).To(Equal(50))
+		Expect(list("/items?limit=51").Code).To(Equal(http.StatusBadRequest))
+
+		Expect(items.SetListOptions(resweave.ListOptions{})).To(Succeed())
+		list("/items")
+		Expect(query.Limit).To(BeZero())
+	})
+
+	It("should write cursor Link headers", func() {
+		next, err := resweave.EncodeCursor(map[string]int{"after": 42})
+		Expect(err).ToNot(HaveOccurred())
+		page = resweave.Page{NextCursor: next, PrevCursor: "prev"}
+		rec := list("/items?cursor=current&limit=5")
+		Expect(rec.Header().Values("Link")).To(Equal([]string{
+			`</items?cursor=` + next + `&limit=5>; rel="next"`,
+			`</items?cursor=prev&limit=5>; rel="prev"`,
+		}))
+
+		var decoded map[string]int
+		Expect(resweave.DecodeCursor(next, &decoded)).To(Succeed())
+		Expect(decoded).To(Equal(map[string]int{"after": 42}))
+		Expect(resweave.DecodeCursor("not a cursor", &decoded)).To(MatchError(resweave.ErrInvalidListQuery))
+		Expect(resweave.DecodeCursor("not a cursor", &decoded)).To(MatchError(resweave.ErrInvalid))
+	})
+})
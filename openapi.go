@@ -0,0 +1,380 @@
+package resweave
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	// OpenAPIResourceName is the name of the resource created by NewOpenAPIResource.
+	OpenAPIResourceName = ResourceName("openapi.json")
+
+	openAPIVersion    = "3.1.0"
+	mediaTypeYAML     = "application/yaml"
+	problemSchemaName = "Problem"
+	headerAccept      = "Accept"
+	headerVary        = "Vary"
+)
+
+// OpenAPIDocument is an OpenAPI 3.1 document; see NewOpenAPIDocument.
+type OpenAPIDocument struct {
+	OpenAPI    string                     `json:"openapi"`
+	Info       OpenAPIInfo                `json:"info"`
+	Paths      map[string]OpenAPIPathItem `json:"paths"`
+	Components OpenAPIComponents          `json:"components,omitzero"`
+}
+
+// OpenAPIInfo is the metadata of an OpenAPIDocument.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIPathItem holds the operations of a path, keyed by lower case HTTP method.
+type OpenAPIPathItem map[string]*OpenAPIOperation
+
+// OpenAPIOperation is an operation on a path, for a single action.
+type OpenAPIOperation struct {
+	OperationID string                     `json:"operationId,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter is a parameter of an operation.
+type OpenAPIParameter struct {
+	Name     string     `json:"name"`
+	In       string     `json:"in"`
+	Required bool       `json:"required,omitempty"`
+	Schema   JSONSchema `json:"schema,omitempty"`
+}
+
+// OpenAPIRequestBody is the request body of an operation.
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse is a response of an operation.
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType describes the content of a request or response body.
+type OpenAPIMediaType struct {
+	Schema JSONSchema `json:"schema,omitempty"`
+}
+
+// OpenAPIComponents holds the schemas referenced from the document.
+type OpenAPIComponents struct {
+	Schemas map[string]JSONSchema `json:"schemas,omitempty"`
+}
+
+// typedResource is implemented by TypedAPIRes, describing its items and IDs for NewOpenAPIDocument.
+type typedResource interface {
+	itemType() reflect.Type
+	idType() reflect.Type
+}
+
+// patchResource is implemented by BaseAPIRes, describing its PATCH media types for NewOpenAPIDocument and whether
+// the methods of its actions include PATCH, for the Routes.
+type patchResource interface {
+	patchMediaTypes() []string
+	servedMethods(actions []ActionType, methods []string) []string
+}
+
+// NewOpenAPIDocument generates an OpenAPI 3.1 document describing the API resource routes, e.g. from Server.Routes or
+// Host.Routes. Routes of other kinds, such as HTML resources, are not described, and routes of different hosts with
+// the same path are merged.
+//
+// Each action becomes an operation, tagged with the resource name, and each ID a path parameter with the ID regex as
+// its pattern. Typed resources describe their request and response bodies with JSON Schemas generated from their item
+// type, and all operations describe their error responses as problem details.
+func NewOpenAPIDocument(info OpenAPIInfo, routes RouteTable) *OpenAPIDocument {
+	g := &openAPIGenerator{
+		doc: &OpenAPIDocument{
+			OpenAPI: openAPIVersion,
+			Info:    info,
+			Paths:   make(map[string]OpenAPIPathItem),
+		},
+		schemas:    newSchemaBuilder(),
+		paramTypes: make(map[string]reflect.Type),
+	}
+	for _, r := range routes {
+		if tr, ok := r.Resource.(typedResource); ok {
+			g.paramTypes[idParamName(r.Resource.Name())] = tr.idType()
+		}
+	}
+	for _, r := range routes {
+		if r.Kind == KindAPI {
+			g.addRoute(r)
+		}
+	}
+	if len(g.doc.Paths) > 0 {
+		g.schemas.schemas[problemSchemaName] = problemSchema()
+	}
+	if len(g.schemas.schemas) > 0 {
+		g.doc.Components.Schemas = g.schemas.schemas
+	}
+	return g.doc
+}
+
+// JSON returns the document as indented JSON.
+func (doc *OpenAPIDocument) JSON() ([]byte, error) {
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// YAML returns the document as YAML, with the members in the same order as JSON.
+func (doc *OpenAPIDocument) YAML() ([]byte, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	blockStyle(&node)
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+// blockStyle clears the flow and quoting styles of the nodes decoded from JSON, so they are encoded as block YAML,
+// quoting only where required.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+// openAPIGenerator builds an OpenAPIDocument from routes.
+type openAPIGenerator struct {
+	doc     *OpenAPIDocument
+	schemas *schemaBuilder
+	// paramTypes are the ID types of typed resources, by path parameter name.
+	paramTypes map[string]reflect.Type
+}
+
+func (g *openAPIGenerator) addRoute(r Route) {
+	item, found := g.doc.Paths[r.Path]
+	if !found {
+		item = make(OpenAPIPathItem)
+	}
+	for _, at := range r.Actions {
+		// Actions are in ActionType order, so a Patch operation replaces that of Update, which also serves PATCH.
+		for _, method := range g.actionMethods(r, at) {
+			item[strings.ToLower(method)] = g.operation(r, at, method)
+		}
+	}
+	if len(item) > 0 {
+		g.doc.Paths[r.Path] = item
+	}
+}
+
+// actionMethods returns the HTTP methods of the action, leaving out the HEAD and OPTIONS served automatically.
+func (g *openAPIGenerator) actionMethods(r Route, at ActionType) []string {
+	if !at.IsCustom() {
+		methods := slices.DeleteFunc(at.methods(), func(m string) bool {
+			return m == http.MethodHead || m == http.MethodOptions
+		})
+		if pr, ok := r.Resource.(patchResource); ok {
+			methods = pr.servedMethods([]ActionType{at}, methods)
+		}
+		return methods
+	}
+	if res, ok := r.Resource.(APIResource); ok {
+		if ca, found := res.CustomActionFor(at); found {
+			return []string{ca.Method}
+		}
+	}
+	return nil
+}
+
+func (g *openAPIGenerator) operation(r Route, at ActionType, method string) *OpenAPIOperation {
+	name := r.actionName(at)
+	if at == Update && method == http.MethodPatch {
+		name = Patch.String()
+	}
+	op := &OpenAPIOperation{
+		OperationID: operationID(r, at, name),
+		Tags:        []string{r.Resource.Name().String()},
+		Responses: map[string]OpenAPIResponse{
+			"default": {
+				Description: "Error",
+				Content:     map[string]OpenAPIMediaType{mediaTypeProblemJSON: {Schema: JSONSchema{"$ref": schemaRefPrefix + problemSchemaName}}},
+			},
+		},
+	}
+	for _, p := range r.Params {
+		op.Parameters = append(op.Parameters, OpenAPIParameter{Name: p.Name, In: "path", Required: true, Schema: g.paramSchema(p)})
+	}
+
+	var item JSONSchema
+	if tr, ok := r.Resource.(typedResource); ok && !at.IsCustom() {
+		item = g.schemas.schema(tr.itemType())
+	}
+	switch {
+	case item != nil && (at == Create || at == Update):
+		op.RequestBody = &OpenAPIRequestBody{Required: true, Content: map[string]OpenAPIMediaType{mediaTypeJSON: {Schema: item}}}
+	case method == http.MethodPatch:
+		op.RequestBody = patchRequestBody(r.Resource)
+	}
+
+	status, description := successResponse(at)
+	resp := OpenAPIResponse{Description: description}
+	if item != nil && at != Delete && at != Patch {
+		if at == List {
+			item = JSONSchema{"type": "array", "items": item}
+		}
+		resp.Content = map[string]OpenAPIMediaType{mediaTypeJSON: {Schema: item}}
+	}
+	op.Responses[status] = resp
+	return op
+}
+
+// paramSchema returns the schema of a path parameter: an integer for the integer IDs of typed resources and
+// otherwise a string matching the ID regex.
+// An integer ID with the default ID regex of a TypedAPIRes is an integer; any other ID is a string with the regex as
+// its pattern, as a custom regex may restrict it further or allow more than an integer.
+func (g *openAPIGenerator) paramSchema(p RouteParam) JSONSchema {
+	if t, found := g.paramTypes[p.Name]; found && t.Kind() != reflect.String && p.ID == typedIntegerID {
+		return JSONSchema{"type": "integer", "minimum": 0}
+	}
+	return JSONSchema{"type": "string", "pattern": string(p.ID)}
+}
+
+// patchRequestBody describes the body of a PATCH request by the media types the resource accepts, if declared.
+func patchRequestBody(r Resource) *OpenAPIRequestBody {
+	pr, ok := r.(patchResource)
+	if !ok || len(pr.patchMediaTypes()) == 0 {
+		return nil
+	}
+	content := make(map[string]OpenAPIMediaType)
+	for _, mt := range pr.patchMediaTypes() {
+		content[mt] = OpenAPIMediaType{}
+	}
+	return &OpenAPIRequestBody{Required: true, Content: content}
+}
+
+// successResponse returns the status and description of the successful response to the action.
+func successResponse(at ActionType) (string, string) {
+	switch at {
+	case Create:
+		return strconv.Itoa(http.StatusCreated), "Created"
+	case Delete:
+		return strconv.Itoa(http.StatusNoContent), "Deleted"
+	case List, Fetch, Update, Patch:
+		return strconv.Itoa(http.StatusOK), "OK"
+	default:
+		return "2XX", "Success"
+	}
+}
+
+// operationID returns the ID of the operation, e.g. fetch_users_emails for Fetch on /users/{users_id}/emails/{emails_id}.
+func operationID(r Route, at ActionType, name string) string {
+	path := r.Path
+	if at.IsCustom() {
+		path = strings.TrimSuffix(path, "/"+actionsSegment+"/"+name)
+	}
+	parts := []string{strings.ToLower(name)}
+	for seg := range strings.SplitSeq(path, "/") {
+		if seg != "" && !strings.HasPrefix(seg, "{") {
+			parts = append(parts, seg)
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+// problemSchema is the schema of the problem details written by WriteError.
+func problemSchema() JSONSchema {
+	str := JSONSchema{"type": "string"}
+	return JSONSchema{
+		"type": "object",
+		"properties": map[string]any{
+			"type":      JSONSchema{"type": "string", "format": "uri-reference"},
+			"title":     str,
+			"status":    JSONSchema{"type": "integer"},
+			"detail":    str,
+			"instance":  JSONSchema{"type": "string", "format": "uri-reference"},
+			"requestId": str,
+		},
+	}
+}
+
+// openAPIResource serves the OpenAPIDocument of the Host serving the request.
+type openAPIResource struct {
+	LogHolder
+	info OpenAPIInfo
+}
+
+// NewOpenAPIResource creates a Resource named openapi.json, serving the OpenAPIDocument for the routes of the Host
+// serving the request as JSON, or as YAML if the Accept header asks for it. The document is generated for each
+// request, so it reflects resources added or removed at runtime.
+func NewOpenAPIResource(info OpenAPIInfo) Resource {
+	return &openAPIResource{info: info, LogHolder: NewLogholder(OpenAPIResourceName.String(), nil)}
+}
+
+func (o *openAPIResource) Name() ResourceName {
+	return OpenAPIResourceName
+}
+
+func (o *openAPIResource) HandleCall(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	ctx = context.WithValue(ctx, KeyResource, Resource(o))
+	if segments, ok := ctx.Value(KeyURISegments).([]ResourceName); ok && len(segments) > 1 {
+		notFound(ctx, w, req)
+		return
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodOptions:
+		w.Header().Set(headerAllow, "GET, HEAD, OPTIONS")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		w.Header().Set(headerAllow, "GET, HEAD, OPTIONS")
+		methodNotAllowed(ctx, w, req)
+		return
+	}
+	h, ok := HostFromContext(ctx)
+	if !ok {
+		WriteError(ctx, w, ErrHostNotFound)
+		return
+	}
+	// The representation depends on the Accept header, so caches must not serve one for the other.
+	w.Header().Add(headerVary, headerAccept)
+	doc := NewOpenAPIDocument(o.info, h.Routes())
+	contentType := mediaTypeJSON
+	body, err := doc.JSON()
+	if strings.Contains(req.Header.Get(headerAccept), "yaml") {
+		contentType = mediaTypeYAML
+		body, err = doc.YAML()
+	}
+	if err != nil {
+		o.Errorw("HandleCall", "Error", err)
+		WriteError(ctx, w, err)
+		return
+	}
+	w.Header().Set(headerContentType, contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if req.Method == http.MethodGet {
+		_, _ = w.Write(body)
+	}
+}
@@ -0,0 +1,202 @@
+package resweave_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/mortedecai/resweave"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.yaml.in/yaml/v3"
+)
+
+type address struct {
+	City string `json:"city"`
+}
+
+type customer struct {
+	ID      int       `json:"id"`
+	Name    string    `json:"name"`
+	Tags    []string  `json:"tags,omitempty"`
+	Home    *address  `json:"home,omitempty"`
+	Joined  time.Time `json:"joined"`
+	Ignored string    `json:"-"`
+}
+
+var _ = Describe("OpenAPI", func() {
+	var (
+		s     resweave.Server
+		info  resweave.OpenAPIInfo
+		notes resweave.APIResource
+	)
+	noop := func(context.Context, http.ResponseWriter, *http.Request) {}
+	get := func(target string, accept string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		s.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		info = resweave.OpenAPIInfo{Title: "Shop", Version: "1.2.0"}
+		s = resweave.NewServer(0)
+		customers := resweave.NewTypedAPI("customers", resweave.TypedHandlers[customer, int]{
+			List:   func(context.Context) ([]customer, error) { return nil, nil },
+			Fetch:  func(context.Context, int) (customer, error) { return customer{}, nil },
+			Create: func(_ context.Context, c customer) (customer, error) { return c, nil },
+			Delete: func(context.Context, int) error { return nil },
+		})
+		notes = resweave.NewAPI("notes")
+		notes.SetFetch(noop)
+		notes.SetPatch(noop)
+		notes.SetPatchMediaTypes("application/merge-patch+json")
+		Expect(notes.SetID(resweave.UUIDv7)).To(Succeed())
+		_, err := notes.AddAction(resweave.CustomAction{Name: "archive", Method: http.MethodPost, Scope: resweave.CollectionScope, Handler: noop})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(customers.AddChildResource(notes)).To(Succeed())
+		Expect(s.AddResource(customers)).To(Succeed())
+		Expect(s.AddResource(resweave.NewHTML("assets", "."))).To(Succeed())
+		Expect(s.AddResource(resweave.NewOpenAPIResource(info))).To(Succeed())
+	})
+
+	Describe("NewOpenAPIDocument", func() {
+		var doc *resweave.OpenAPIDocument
+		BeforeEach(func() {
+			doc = resweave.NewOpenAPIDocument(info, s.Routes())
+		})
+
+		It("should describe the API resource paths and their actions", func() {
+			Expect(doc.OpenAPI).To(Equal("3.1.0"))
+			Expect(doc.Info).To(Equal(info))
+			Expect(doc.Paths).To(HaveLen(4))
+			Expect(doc.Paths["/customers"]).To(HaveKey("get"))
+			Expect(doc.Paths["/customers"]).To(HaveKey("post"))
+			Expect(doc.Paths["/customers/{customers_id}"]).To(SatisfyAll(HaveKey("get"), HaveKey("delete"), HaveLen(2)))
+			Expect(doc.Paths["/customers/{customers_id}/notes/{notes_id}"]).To(SatisfyAll(HaveKey("get"), HaveKey("patch"), HaveLen(2)))
+			Expect(doc.Paths).To(HaveKey("/customers/{customers_id}/notes/actions/archive"))
+			Expect(doc.Paths).ToNot(HaveKey("/assets/*"))
+			Expect(doc.Paths).ToNot(HaveKey("/openapi.json"))
+
+			Expect(doc.Paths["/customers/{customers_id}"]["get"].OperationID).To(Equal("fetch_customers"))
+			Expect(doc.Paths["/customers/{customers_id}/notes/actions/archive"]["post"].OperationID).To(Equal("archive_customers_notes"))
+			Expect(doc.Paths["/customers/{customers_id}/notes/{notes_id}"]["patch"].Tags).To(Equal([]string{"notes"}))
+		})
+
+		It("should describe IDs as path parameters", func() {
+			params := doc.Paths["/customers/{customers_id}/notes/{notes_id}"]["get"].Parameters
+			Expect(params).To(Equal([]resweave.OpenAPIParameter{
+				{Name: "customers_id", In: "path", Required: true, Schema: resweave.JSONSchema{"type": "integer", "minimum": 0}},
+				{Name: "notes_id", In: "path", Required: true, Schema: resweave.JSONSchema{"type": "string", "pattern": string(resweave.UUIDv7)}},
+			}))
+		})
+
+		It("should describe typed integer IDs with a custom regex by the regex", func() {
+			orders := resweave.NewTypedAPI("orders", resweave.TypedHandlers[customer, uint16]{
+				Fetch: func(context.Context, uint16) (customer, error) { return customer{}, nil },
+			})
+			Expect(orders.SetID(`^[1-9][0-9]{0,3}$`)).To(Succeed())
+			Expect(s.AddResource(orders)).To(Succeed())
+			params := resweave.NewOpenAPIDocument(info, s.Routes()).Paths["/orders/{orders_id}"]["get"].Parameters
+			Expect(params).To(Equal([]resweave.OpenAPIParameter{
+				{Name: "orders_id", In: "path", Required: true, Schema: resweave.JSONSchema{"type": "string", "pattern": `^[1-9][0-9]{0,3}$`}},
+			}))
+		})
+
+		It("should describe typed request and response bodies", func() {
+			ref := resweave.JSONSchema{"$ref": "#/components/schemas/customer"}
+			create := doc.Paths["/customers"]["post"]
+			Expect(create.RequestBody.Content["application/json"].Schema).To(Equal(ref))
+			Expect(create.Responses["201"].Content["application/json"].Schema).To(Equal(ref))
+			Expect(doc.Paths["/customers"]["get"].Responses["200"].Content["application/json"].Schema).
+				To(Equal(resweave.JSONSchema{"type": "array", "items": ref}))
+			Expect(doc.Paths["/customers/{customers_id}"]["delete"].Responses["204"].Content).To(BeEmpty())
+			Expect(create.Responses["default"].Content).To(HaveKey("application/problem+json"))
+
+			schemas := doc.Components.Schemas
+			Expect(schemas).To(HaveKey("Problem"))
+			Expect(schemas["address"]).To(Equal(resweave.JSONSchema{
+				"type":       "object",
+				"properties": map[string]any{"city": resweave.JSONSchema{"type": "string"}},
+				"required":   []string{"city"},
+			}))
+			Expect(schemas["customer"]["required"]).To(Equal([]string{"id", "name", "joined"}))
+			Expect(schemas["customer"]["properties"]).To(Equal(map[string]any{
+				"id":     resweave.JSONSchema{"type": "integer"},
+				"name":   resweave.JSONSchema{"type": "string"},
+				"tags":   resweave.JSONSchema{"type": "array", "items": resweave.JSONSchema{"type": "string"}},
+				"home":   resweave.JSONSchema{"$ref": "#/components/schemas/address"},
+				"joined": resweave.JSONSchema{"type": "string", "format": "date-time"},
+			}))
+		})
+
+		It("should describe PATCH bodies by their media types", func() {
+			patch := doc.Paths["/customers/{customers_id}/notes/{notes_id}"]["patch"]
+			Expect(patch.RequestBody.Content).To(HaveKey("application/merge-patch+json"))
+		})
+
+		It("should render as JSON and YAML", func() {
+			data, err := doc.JSON()
+			Expect(err).ToNot(HaveOccurred())
+			var fromJSON map[string]any
+			Expect(json.Unmarshal(data, &fromJSON)).To(Succeed())
+
+			data, err = doc.YAML()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(HavePrefix("openapi: 3.1.0\ninfo:\n  title: Shop\n"))
+			var fromYAML map[string]any
+			Expect(yaml.Unmarshal(data, &fromYAML)).To(Succeed())
+			Expect(fromYAML).To(HaveKeyWithValue("paths", HaveKey("/customers/{customers_id}")))
+			Expect(fromYAML["paths"]).To(HaveKeyWithValue("/customers", HaveKeyWithValue("post",
+				HaveKeyWithValue("responses", HaveKey("201")))))
+		})
+	})
+
+	Describe("NewOpenAPIResource", func() {
+		It("should serve the document of the host as JSON", func() {
+			rec := get("/openapi.json", "")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(rec.Header().Get("Vary")).To(Equal("Accept"))
+			var doc resweave.OpenAPIDocument
+			Expect(json.Unmarshal(rec.Body.Bytes(), &doc)).To(Succeed())
+			Expect(doc.Info.Title).To(Equal("Shop"))
+			Expect(doc.Paths).To(HaveKey("/customers"))
+		})
+
+		It("should serve YAML when asked for", func() {
+			rec := get("/openapi.json", "application/yaml")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Header().Get("Content-Type")).To(Equal("application/yaml"))
+			Expect(rec.Header().Get("Vary")).To(Equal("Accept"))
+			Expect(rec.Body.String()).To(HavePrefix("openapi: 3.1.0\n"))
+		})
+
+		It("should reflect resources added at runtime", func() {
+			orders := resweave.NewAPI("orders")
+			orders.SetList(noop)
+			Expect(s.AddResource(orders)).To(Succeed())
+			Expect(get("/openapi.json", "").Body.String()).To(ContainSubstring(`"/orders"`))
+		})
+
+		It("should only answer GET, HEAD and OPTIONS", func() {
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/openapi.json", nil))
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(BeEmpty())
+			Expect(rec.Header().Get("Content-Length")).ToNot(BeEmpty())
+
+			rec = httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/openapi.json", nil))
+			Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(rec.Header().Get("Allow")).To(Equal("GET, HEAD, OPTIONS"))
+
+			Expect(get("/openapi.json/extra", "").Code).To(Equal(http.StatusNotFound))
+		})
+	})
+})
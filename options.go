@@ -0,0 +1,130 @@
+package resweave
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultReadHeaderTimeout = 3 * time.Second
+)
+
+// ServerOption configures optional behaviour of a Server created with NewServer.
+type ServerOption func(s *server)
+
+// ConnStateHook is called when a client connection changes state; see http.Server.ConnState.
+type ConnStateHook func(conn net.Conn, state http.ConnState)
+
+// httpOptions holds the http.Server tuning applied by createHTTPServer.
+type httpOptions struct {
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	maxHeaderBytes    int
+	disableKeepAlives bool
+	connStateHooks    []ConnStateHook
+	maxConnections    int
+	h2c               bool
+	http2             *http.HTTP2Config
+}
+
+// WithReadTimeout sets the maximum duration for reading an entire request, including the body.
+func WithReadTimeout(d time.Duration) ServerOption {
+	return func(s *server) {
+		s.httpOpts.readTimeout = d
+	}
+}
+
+// WithReadHeaderTimeout sets the maximum duration for reading request headers. The default is 3 seconds.
+func WithReadHeaderTimeout(d time.Duration) ServerOption {
+	return func(s *server) {
+		s.httpOpts.readHeaderTimeout = d
+	}
+}
+
+// WithWriteTimeout sets the maximum duration before timing out writes of a response.
+func WithWriteTimeout(d time.Duration) ServerOption {
+	return func(s *server) {
+		s.httpOpts.writeTimeout = d
+	}
+}
+
+// WithIdleTimeout sets the maximum time to wait for the next request on a keep-alive connection.
+func WithIdleTimeout(d time.Duration) ServerOption {
+	return func(s *server) {
+		s.httpOpts.idleTimeout = d
+	}
+}
+
+// WithMaxHeaderBytes sets the maximum number of bytes read parsing request headers, including the request line.
+func WithMaxHeaderBytes(n int) ServerOption {
+	return func(s *server) {
+		s.httpOpts.maxHeaderBytes = n
+	}
+}
+
+// WithKeepAlives enables or disables HTTP keep-alives. Keep-alives are enabled by default.
+func WithKeepAlives(enabled bool) ServerOption {
+	return func(s *server) {
+		s.httpOpts.disableKeepAlives = !enabled
+	}
+}
+
+// WithConnStateHook adds a hook called whenever a client connection changes state.
+// It may be used multiple times; hooks are called in the order they were added.
+func WithConnStateHook(hook ConnStateHook) ServerOption {
+	return func(s *server) {
+		if hook != nil {
+			s.httpOpts.connStateHooks = append(s.httpOpts.connStateHooks, hook)
+		}
+	}
+}
+
+// WithMaxConnections limits the number of concurrently open connections across all of the Server's listeners.
+// Once the limit is reached, new connections wait to be accepted until an open connection is closed.
+func WithMaxConnections(n int) ServerOption {
+	return func(s *server) {
+		s.httpOpts.maxConnections = n
+	}
+}
+
+// WithH2C serves HTTP/2 over cleartext (h2c) with prior knowledge, alongside HTTP/1.1, on non-TLS listeners.
+// This allows callers such as an internal service mesh to multiplex requests without a TLS terminator.
+func WithH2C() ServerOption {
+	return func(s *server) {
+		s.httpOpts.h2c = true
+	}
+}
+
+// WithHTTP2Config tunes the HTTP/2 settings (e.g. MaxConcurrentStreams) used for both h2c and HTTP/2 over TLS.
+func WithHTTP2Config(cfg http.HTTP2Config) ServerOption {
+	return func(s *server) {
+		s.httpOpts.http2 = &cfg
+	}
+}
+
+// protocols returns the protocols to serve, or nil to use the http.Server defaults (HTTP/1.1, and HTTP/2 over TLS).
+func (o httpOptions) protocols() *http.Protocols {
+	if !o.h2c {
+		return nil
+	}
+	p := &http.Protocols{}
+	p.SetHTTP1(true)
+	p.SetHTTP2(true)
+	p.SetUnencryptedHTTP2(true)
+	return p
+}
+
+func (o httpOptions) connState() func(net.Conn, http.ConnState) {
+	if len(o.connStateHooks) == 0 {
+		return nil
+	}
+	hooks := o.connStateHooks
+	return func(conn net.Conn, state http.ConnState) {
+		for _, hook := range hooks {
+			hook(conn, state)
+		}
+	}
+}
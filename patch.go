@@ -0,0 +1,68 @@
+package resweave
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const (
+	headerAcceptPatch = "Accept-Patch"
+	headerContentType = "Content-Type"
+)
+
+func (bar *BaseAPIRes) SetPatchMediaTypes(mediaTypes ...string) {
+	types := make([]string, 0, len(mediaTypes))
+	for _, mt := range mediaTypes {
+		types = append(types, strings.ToLower(strings.TrimSpace(mt)))
+	}
+	bar.patchTypes.Store(&types)
+}
+
+// disablePatchFallback stops PATCH requests being passed to Update when no Patch handler is set, so they are answered
+// with a 405 and PATCH is left out of the allowed methods.
+func (bar *BaseAPIRes) disablePatchFallback() {
+	bar.noPatchFallback.Store(true)
+}
+
+// servedMethods returns the methods of the actions, leaving out PATCH if none of the actions serves it.
+func (bar *BaseAPIRes) servedMethods(actions []ActionType, methods []string) []string {
+	if bar.noPatchFallback.Load() && !slices.Contains(actions, Patch) {
+		return slices.DeleteFunc(methods, func(m string) bool { return m == http.MethodPatch })
+	}
+	return methods
+}
+
+// patchMediaTypes returns the media types accepted for PATCH requests; empty if any are accepted.
+func (bar *BaseAPIRes) patchMediaTypes() []string {
+	if types := bar.patchTypes.Load(); types != nil {
+		return *types
+	}
+	return nil
+}
+
+// setAcceptPatch sets the Accept-Patch header if the resource declares its PATCH media types.
+func (bar *BaseAPIRes) setAcceptPatch(w http.ResponseWriter) {
+	if types := bar.patchMediaTypes(); len(types) > 0 {
+		w.Header().Set(headerAcceptPatch, strings.Join(types, ", "))
+	}
+}
+
+// acceptsPatch returns true if the Content-Type of the PATCH request is one of the declared media types, ignoring
+// parameters such as charset, or if no media types are declared.
+func (bar *BaseAPIRes) acceptsPatch(req *http.Request) bool {
+	types := bar.patchMediaTypes()
+	if len(types) == 0 {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(req.Header.Get(headerContentType))
+	return err == nil && slices.Contains(types, mt)
+}
+
+// unsupportedPatch answers a PATCH request with an undeclared Content-Type with a 415 and the accepted media types.
+func (bar *BaseAPIRes) unsupportedPatch(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	bar.setAcceptPatch(w)
+	WriteError(ctx, w, errUnsupportedMediaType(req.Header.Get(headerContentType)))
+}
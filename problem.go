@@ -0,0 +1,179 @@
+package resweave
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+)
+
+const (
+	// KeyRequestPath is the context key for the path of the incoming request, used as the instance of a Problem.
+	KeyRequestPath = Key("INCOMING_REQUEST_PATH")
+
+	keyErrorMappings     = Key("ERROR_MAPPINGS")
+	mediaTypeProblemJSON = "application/problem+json"
+)
+
+// ErrMethodNotAllowed is the error written for requests for an action without a handler; it is answered with a 405.
+var ErrMethodNotAllowed = errors.New("method not allowed")
+
+// Problem is an RFC 9457 problem details object, written as application/problem+json by WriteError.
+// It may be returned as an error to control every member of the response.
+type Problem struct {
+	// Type is a URI identifying the problem type; omitted, and so "about:blank", if empty.
+	Type string `json:"type,omitempty"`
+	// Title is a short summary of the problem type; the status text if empty.
+	Title string `json:"title,omitempty"`
+	// Status is the HTTP status code.
+	Status int `json:"status,omitempty"`
+	// Detail is an explanation specific to this occurrence of the problem.
+	Detail string `json:"detail,omitempty"`
+	// Instance is a URI identifying this occurrence; the request path if empty.
+	Instance string `json:"instance,omitempty"`
+	// RequestID is the ID of the request, from KeyRequestID.
+	RequestID string `json:"requestId,omitempty"`
+	// Extensions are additional members of the problem details object. They cannot replace the members above.
+	Extensions map[string]any `json:"-"`
+	// Err is the underlying error, if any; it is not written.
+	Err error `json:"-"`
+}
+
+func (p *Problem) Error() string {
+	switch {
+	case p.Detail != "":
+		return p.Detail
+	case p.Title != "":
+		return p.Title
+	case p.Err != nil:
+		return p.Err.Error()
+	default:
+		return http.StatusText(p.Status)
+	}
+}
+
+func (p *Problem) Unwrap() error {
+	return p.Err
+}
+
+// StatusCode returns the Status of the problem, making Problem a StatusCoder.
+func (p *Problem) StatusCode() int {
+	return p.Status
+}
+
+// MarshalJSON writes the problem members along with any Extensions.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	members, err := json.Marshal((*problem)(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return members, err
+	}
+	merged := maps.Clone(p.Extensions)
+	var standard map[string]any
+	if err := json.Unmarshal(members, &standard); err != nil {
+		return nil, err
+	}
+	maps.Copy(merged, standard)
+	return json.Marshal(merged)
+}
+
+// errorMapping maps errors wrapping target to an HTTP status.
+type errorMapping struct {
+	target error
+	status int
+}
+
+var defaultErrorMappings = []errorMapping{
+	{ErrNotFound, http.StatusNotFound},
+	{ErrIDNotFound, http.StatusNotFound},
+	{ErrResourceNotFound, http.StatusNotFound},
+	{ErrChildResourceNotFound, http.StatusNotFound},
+	{ErrHostNotFound, http.StatusNotFound},
+	{ErrMethodNotAllowed, http.StatusMethodNotAllowed},
+	{ErrConflict, http.StatusConflict},
+	{ErrResourceAlreadyExists, http.StatusConflict},
+	{ErrChildResourceAlreadyExists, http.StatusConflict},
+	{ErrActionAlreadyExists, http.StatusConflict},
+	{ErrInvalid, http.StatusBadRequest},
+	{ErrPreconditionFailed, http.StatusPreconditionFailed},
+	{ErrPreconditionRequired, http.StatusPreconditionRequired},
+	{ErrIdempotencyKeyMismatch, http.StatusUnprocessableEntity},
+	{ErrRequestTooLarge, http.StatusRequestEntityTooLarge},
+}
+
+// WithErrorMapping maps errors wrapping target to the HTTP status written by WriteError for requests to the Server.
+// Mappings take precedence over the built-in mappings, and later mappings over earlier ones, but not over a
+// StatusCoder in the error chain.
+func WithErrorMapping(target error, status int) ServerOption {
+	return func(s *server) {
+		s.errorMappings = append(s.errorMappings, errorMapping{target: target, status: status})
+	}
+}
+
+// withErrorMappings returns ctx carrying the mappings of a Server.
+func withErrorMappings(ctx context.Context, mappings []errorMapping) context.Context {
+	return context.WithValue(ctx, keyErrorMappings, slices.Clip(mappings))
+}
+
+// errorStatus returns the HTTP status for err: that of a StatusCoder in the error chain, a mapping of the Server
+// in the context, a built-in mapping, or 500.
+func errorStatus(ctx context.Context, err error) int {
+	var sc StatusCoder
+	if errors.As(err, &sc) && sc.StatusCode() != 0 {
+		return sc.StatusCode()
+	}
+	mappings, _ := ctx.Value(keyErrorMappings).([]errorMapping)
+	for i := len(mappings) - 1; i >= 0; i-- {
+		if m := mappings[i]; errors.Is(err, m.target) {
+			return m.status
+		}
+	}
+	for _, m := range defaultErrorMappings {
+		if errors.Is(err, m.target) {
+			return m.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// NewProblem returns the Problem WriteError would write for err in the context.
+// The detail of errors with a 5xx status is omitted, to avoid exposing internal errors, unless err is a Problem.
+func NewProblem(ctx context.Context, err error) *Problem {
+	p := &Problem{Err: err}
+	var existing *Problem
+	if errors.As(err, &existing) {
+		*p = *existing
+	}
+	if p.Status == 0 {
+		p.Status = errorStatus(ctx, err)
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Detail == "" && existing == nil && p.Status < http.StatusInternalServerError {
+		p.Detail = err.Error()
+	}
+	if p.Instance == "" {
+		p.Instance, _ = ctx.Value(KeyRequestPath).(string)
+	}
+	if p.RequestID == "" {
+		p.RequestID, _ = ctx.Value(KeyRequestID).(string)
+	}
+	return p
+}
+
+// WriteError writes err as an application/problem+json response; see NewProblem.
+func WriteError(ctx context.Context, w http.ResponseWriter, err error) {
+	p := NewProblem(ctx, err)
+	body, merr := json.Marshal(p)
+	if merr != nil {
+		w.WriteHeader(p.Status)
+		return
+	}
+	w.Header().Set(headerContentType, mediaTypeProblemJSON)
+	w.Header().Del("Content-Length")
+	w.WriteHeader(p.Status)
+	_, _ = w.Write(body)
+}
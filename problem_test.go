@@ -0,0 +1,129 @@
+package resweave_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/mortedecai/resweave"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Problem Details", func() {
+	var ctx context.Context
+	write := func(err error) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		resweave.WriteError(ctx, recorder, err)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		ctx = context.WithValue(context.Background(), resweave.KeyRequestID, "req-1")
+		ctx = context.WithValue(ctx, resweave.KeyRequestPath, "/orders/7")
+	})
+
+	It("should write application/problem+json with the request ID and path", func() {
+		rec := write(fmt.Errorf("order 7: %w", resweave.ErrNotFound))
+		Expect(rec.Code).To(Equal(http.StatusNotFound))
+		Expect(rec.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+		Expect(rec.Body.String()).To(MatchJSON(`{
+			"title": "Not Found",
+			"status": 404,
+			"detail": "order 7: not found",
+			"instance": "/orders/7",
+			"requestId": "req-1"
+		}`))
+	})
+
+	DescribeTable("should map sentinel errors to statuses",
+		func(err error, status int) {
+			Expect(write(err).Code).To(Equal(status))
+		},
+		Entry("ErrIDNotFound", resweave.ErrIDNotFound, http.StatusNotFound),
+		Entry("ErrResourceNotFound", resweave.ErrResourceNotFound, http.StatusNotFound),
+		Entry("ErrConflict", resweave.ErrConflict, http.StatusConflict),
+		Entry("ErrResourceAlreadyExists", resweave.ErrResourceAlreadyExists, http.StatusConflict),
+		Entry("ErrInvalid", resweave.ErrInvalid, http.StatusBadRequest),
+		Entry("ErrMethodNotAllowed", resweave.ErrMethodNotAllowed, http.StatusMethodNotAllowed),
+		Entry("a StatusCoder", teapotError{}, http.StatusTeapot),
+		Entry("anything else", errors.New("boom"), http.StatusInternalServerError),
+	)
+
+	It("should not expose the detail of server errors", func() {
+		rec := write(errors.New("dial tcp 10.0.0.1:5432: connection refused"))
+		Expect(rec.Body.String()).To(MatchJSON(`{
+			"title": "Internal Server Error",
+			"status": 500,
+			"instance": "/orders/7",
+			"requestId": "req-1"
+		}`))
+	})
+
+	It("should use the error mappings of the Server", func() {
+		errPaymentRequired := errors.New("payment required")
+		errGone := fmt.Errorf("gone: %w", resweave.ErrNotFound)
+		var err error
+		s := resweave.NewServer(0,
+			resweave.WithErrorMapping(errPaymentRequired, http.StatusPaymentRequired),
+			resweave.WithErrorMapping(resweave.ErrNotFound, http.StatusTeapot),
+			resweave.WithErrorMapping(errGone, http.StatusGone),
+		)
+		orders := resweave.NewAPI("orders")
+		orders.SetList(func(ctx context.Context, w http.ResponseWriter, _ *http.Request) {
+			resweave.WriteError(ctx, w, err)
+		})
+		Expect(s.AddResource(orders)).To(Succeed())
+		serve := func(e error) int {
+			err = e
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+			return rec.Code
+		}
+
+		Expect(serve(fmt.Errorf("order 7: %w", errPaymentRequired))).To(Equal(http.StatusPaymentRequired))
+		Expect(serve(errGone)).To(Equal(http.StatusGone))
+		Expect(serve(resweave.ErrNotFound)).To(Equal(http.StatusTeapot))
+		Expect(serve(teapotError{})).To(Equal(http.StatusTeapot))
+
+		Expect(write(errPaymentRequired).Code).To(Equal(http.StatusInternalServerError))
+		other := resweave.NewServer(0)
+		Expect(other.AddResource(orders)).To(Succeed())
+		rec := httptest.NewRecorder()
+		err = errGone
+		other.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+		Expect(rec.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should write a Problem returned as an error as is, with its extensions", func() {
+		rec := write(fmt.Errorf("wrapped: %w", &resweave.Problem{
+			Type:       "https://example.com/problems/out-of-credit",
+			Title:      "You do not have enough credit.",
+			Status:     http.StatusForbidden,
+			Detail:     "Your current balance is 30, but that costs 50.",
+			Extensions: map[string]any{"balance": 30, "status": 999},
+		}))
+		Expect(rec.Code).To(Equal(http.StatusForbidden))
+		Expect(rec.Body.String()).To(MatchJSON(`{
+			"type": "https://example.com/problems/out-of-credit",
+			"title": "You do not have enough credit.",
+			"status": 403,
+			"detail": "Your current balance is 30, but that costs 50.",
+			"instance": "/orders/7",
+			"requestId": "req-1",
+			"balance": 30
+		}`))
+	})
+
+	It("should be used for HTML resource errors", func() {
+		s := resweave.NewServer(0)
+		Expect(s.AddResource(resweave.NewHTML("missing", "./does-not-exist"))).To(Succeed())
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/missing/", nil))
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"instance":"/missing/"`))
+	})
+})
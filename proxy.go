@@ -0,0 +1,83 @@
+package resweave
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const (
+	headerForwarded      = "Forwarded"
+	headerXForwardedHost = "X-Forwarded-Host"
+)
+
+// WithTrustedProxies sets the networks of the proxies (e.g. load balancers) whose forwarding headers are trusted.
+// When the direct peer of a request is within one of the prefixes, the Host is resolved from the host= parameter of
+// the Forwarded header (RFC 7239) or, failing that, the X-Forwarded-Host header. Only the last (right-most) value is
+// used, as it was added by the trusted peer itself; earlier values may have been sent by the client. Forwarding
+// headers from any other peer are ignored.
+func WithTrustedProxies(prefixes ...netip.Prefix) ServerOption {
+	return func(s *server) {
+		s.trustedProxies = append(s.trustedProxies, prefixes...)
+	}
+}
+
+// isTrustedProxy returns true if the request's direct peer is a trusted proxy.
+func (s *server) isTrustedProxy(req *http.Request) bool {
+	if len(s.trustedProxies) == 0 {
+		return false
+	}
+	addrPort, err := netip.ParseAddrPort(req.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := addrPort.Addr().Unmap()
+	for _, p := range s.trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveForwardedHost returns the request with its Host set from the forwarding headers if the peer is a trusted proxy.
+func (s *server) resolveForwardedHost(req *http.Request) *http.Request {
+	if !s.isTrustedProxy(req) {
+		return req
+	}
+	host := forwardedHost(req.Header)
+	if host == "" {
+		return req
+	}
+	s.Debugw("resolveForwardedHost", "Peer", req.RemoteAddr, "Host", req.Host, "Forwarded Host", host)
+	r := req.WithContext(req.Context())
+	r.Host = host
+	return r
+}
+
+// forwardedHost returns the host of the last element of the Forwarded header, falling back to the last
+// X-Forwarded-Host.
+func forwardedHost(header http.Header) string {
+	if element := lastElement(header.Values(headerForwarded)); element != "" {
+		for pair := range strings.SplitSeq(element, ";") {
+			key, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+			if found && strings.EqualFold(key, "host") {
+				return strings.Trim(value, `"`)
+			}
+		}
+	}
+	return lastElement(header.Values(headerXForwardedHost))
+}
+
+// lastElement returns the last comma separated element of the header values. Proxies append to forwarding headers,
+// so only the last element was added by the direct peer; those before it may come from the client.
+func lastElement(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	v := values[len(values)-1]
+	if i := strings.LastIndex(v, ","); i >= 0 {
+		v = v[i+1:]
+	}
+	return strings.TrimSpace(v)
+}
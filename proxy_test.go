@@ -0,0 +1,107 @@
+package resweave
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Host Resolution", func() {
+	var (
+		s Server
+	)
+	// get serves a request from the peer, returning the name of the Host which served it.
+	get := func(target string, peer string, header http.Header) string {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.RemoteAddr = peer
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		s.ServeHTTP(recorder, req)
+		return recorder.Body.String()
+	}
+	serveHost := func(h Host) {
+		api := NewAPI("")
+		api.SetList(func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(h.Name()))
+		})
+		Expect(h.AddResource(api)).To(Succeed())
+	}
+	BeforeEach(func() {
+		s = NewServer(0, WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")))
+		serveHost(s.(*server).getDefaultHost())
+		for _, name := range []HostName{"example.com", "Public.Example.COM.", "::1"} {
+			h, err := s.AddHost(name)
+			Expect(err).ToNot(HaveOccurred())
+			serveHost(h)
+		}
+	})
+
+	Describe("Normalization", func() {
+		It("should normalize the names of added hosts", func() {
+			_, found := s.GetHost("public.example.com")
+			Expect(found).To(BeTrue())
+			_, err := s.AddHost("EXAMPLE.com")
+			Expect(err).To(HaveOccurred())
+		})
+		It("should match request hosts regardless of case, port and trailing dot", func() {
+			Expect(get("http://EXAMPLE.com.:8080/", "192.0.2.1:1234", nil)).To(Equal("example.com"))
+		})
+		It("should match IPv6 literals", func() {
+			Expect(get("http://[::1]:8080/", "192.0.2.1:1234", nil)).To(Equal("::1"))
+		})
+	})
+
+	Describe("Forwarding Headers", func() {
+		It("should use X-Forwarded-Host from a trusted proxy", func() {
+			header := http.Header{"X-Forwarded-Host": {"Public.example.com"}}
+			Expect(get("http://example.com/", "10.1.2.3:1234", header)).To(Equal("public.example.com"))
+			Expect(get("http://example.com/", "[fd12::1]:1234", header)).To(Equal("public.example.com"))
+		})
+		It("should prefer the host of the Forwarded header", func() {
+			header := http.Header{
+				"Forwarded":        {`for=192.0.2.60;host=example.com, for=198.51.100.7;proto=https;host="public.example.com:443"`},
+				"X-Forwarded-Host": {"example.com"},
+			}
+			Expect(get("http://internal/", "10.1.2.3:1234", header)).To(Equal("public.example.com"))
+		})
+		It("should only use the value added by the trusted proxy, not one sent by the client", func() {
+			_, err := s.AddHost("admin.internal")
+			Expect(err).ToNot(HaveOccurred())
+			header := http.Header{"X-Forwarded-Host": {"admin.internal, public.example.com"}}
+			Expect(get("http://example.com/", "10.1.1.1:1234", header)).To(Equal("public.example.com"))
+			header = http.Header{"X-Forwarded-Host": {"admin.internal", "public.example.com"}}
+			Expect(get("http://example.com/", "10.1.1.1:1234", header)).To(Equal("public.example.com"))
+			header = http.Header{"Forwarded": {"host=admin.internal", "for=192.0.2.60;host=public.example.com"}}
+			Expect(get("http://example.com/", "10.1.1.1:1234", header)).To(Equal("public.example.com"))
+		})
+		It("should fall back to X-Forwarded-Host when Forwarded has no host", func() {
+			header := http.Header{
+				"Forwarded":        {"for=192.0.2.60;proto=https"},
+				"X-Forwarded-Host": {"public.example.com"},
+			}
+			Expect(get("http://internal/", "10.1.2.3:1234", header)).To(Equal("public.example.com"))
+		})
+		It("should ignore forwarding headers from untrusted peers", func() {
+			header := http.Header{
+				"Forwarded":        {"host=public.example.com"},
+				"X-Forwarded-Host": {"public.example.com"},
+			}
+			Expect(get("http://example.com/", "192.0.2.1:1234", header)).To(Equal("example.com"))
+		})
+		It("should ignore forwarding headers when no proxies are trusted", func() {
+			s = NewServer(0)
+			serveHost(s.(*server).getDefaultHost())
+			h, err := s.AddHost("public.example.com")
+			Expect(err).ToNot(HaveOccurred())
+			serveHost(h)
+			header := http.Header{"X-Forwarded-Host": {"public.example.com"}}
+			Expect(get("http://example.com/", "10.1.2.3:1234", header)).To(Equal(""))
+		})
+	})
+})
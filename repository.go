@@ -0,0 +1,275 @@
+package resweave
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidIdentity is returned when creating a Repository with an Identity missing its ID or SetID function.
+var ErrInvalidIdentity = errors.New("invalid repository identity")
+
+// Repository stores items of type T identified by IDs of type K; see NewRepositoryAPI.
+// Implementations return errors wrapping ErrNotFound for unknown IDs and ErrConflict for IDs already in use.
+type Repository[T any, K TypedID] interface {
+	// List returns every item, in ID order.
+	List(ctx context.Context) ([]T, error)
+	// Get returns the item with the ID.
+	Get(ctx context.Context, id K) (T, error)
+	// Create stores v, generating its ID if it has the zero ID, and returns the stored item.
+	Create(ctx context.Context, v T) (T, error)
+	// Update replaces the item with the ID with v, setting its ID, and returns the stored item.
+	Update(ctx context.Context, id K, v T) (T, error)
+	// Delete removes the item with the ID.
+	Delete(ctx context.Context, id K) error
+}
+
+// IDGenerator returns a new ID for which exists returns false.
+type IDGenerator[K TypedID] func(exists func(K) bool) (K, error)
+
+// Identity describes how a Repository reads, sets and generates the IDs of its items.
+type Identity[T any, K TypedID] struct {
+	// ID returns the ID of the item. Required.
+	ID func(T) K
+	// SetID sets the ID of the item. Required.
+	SetID func(*T, K)
+	// Generate generates IDs for items created with the zero ID.
+	// If nil, items must be created with an ID.
+	Generate IDGenerator[K]
+}
+
+// SequentialIDs returns an IDGenerator counting up from 1, skipping IDs already in use.
+// Once the largest value of K has been generated, it returns an error wrapping ErrConflict.
+func SequentialIDs[K IntegerID]() IDGenerator[K] {
+	var last atomic.Uint64
+	limit := maxIntegerID[K]()
+	return func(exists func(K) bool) (K, error) {
+		for {
+			n := last.Add(1)
+			if n > limit {
+				return 0, fmt.Errorf("%w: sequential IDs exhausted", ErrConflict)
+			}
+			if id := K(n); !exists(id) {
+				return id, nil
+			}
+		}
+	}
+}
+
+// maxIntegerID returns the largest value of the integer type K.
+func maxIntegerID[K IntegerID]() uint64 {
+	t := reflect.TypeFor[K]()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return 1<<(t.Bits()-1) - 1
+	default:
+		return math.MaxUint64 >> (64 - t.Bits())
+	}
+}
+
+// UUIDs returns an IDGenerator of version 7 UUIDs.
+func UUIDs[K ~string]() IDGenerator[K] {
+	return func(exists func(K) bool) (K, error) {
+		for {
+			u, err := uuid.NewV7()
+			if err != nil {
+				return "", err
+			}
+			if id := K(u.String()); !exists(id) {
+				return id, nil
+			}
+		}
+	}
+}
+
+// repository is a concurrency-safe map based Repository, optionally persisting its items after each change.
+type repository[T any, K TypedID] struct {
+	identity Identity[T, K]
+	mtx      sync.RWMutex
+	items    map[K]T
+	// persist is called with every item, in ID order, before a change is committed; the change is discarded if it
+	// returns an error.
+	persist func([]T) error
+}
+
+// NewMemoryRepository creates an empty Repository holding its items in memory.
+func NewMemoryRepository[T any, K TypedID](identity Identity[T, K]) (Repository[T, K], error) {
+	return newRepository(identity)
+}
+
+// NewFileRepository creates a Repository holding its items in memory and persisting them as a JSON array to the
+// file at path after each change. Existing items are loaded from the file if it exists.
+// Each change is written to a temporary file in the same directory which is then renamed over path, so the file
+// always holds a complete set of items.
+func NewFileRepository[T any, K TypedID](path string, identity Identity[T, K]) (Repository[T, K], error) {
+	r, err := newRepository(identity)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		var items []T
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("loading '%s': %w", path, err)
+		}
+		for _, item := range items {
+			r.items[identity.ID(item)] = item
+		}
+	}
+	r.persist = func(items []T) error {
+		return writeFileAtomic(path, items)
+	}
+	return r, nil
+}
+
+func newRepository[T any, K TypedID](identity Identity[T, K]) (*repository[T, K], error) {
+	if identity.ID == nil || identity.SetID == nil {
+		return nil, fmt.Errorf("%w: ID and SetID are required", ErrInvalidIdentity)
+	}
+	return &repository[T, K]{identity: identity, items: make(map[K]T)}, nil
+}
+
+func (r *repository[T, K]) List(_ context.Context) ([]T, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	return r.sorted(r.items), nil
+}
+
+func (r *repository[T, K]) Get(_ context.Context, id K) (T, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	item, found := r.items[id]
+	if !found {
+		return item, fmt.Errorf("%w: '%v'", ErrNotFound, id)
+	}
+	return item, nil
+}
+
+func (r *repository[T, K]) Create(_ context.Context, v T) (T, error) {
+	err := r.update(func(items map[K]T) error {
+		var zero K
+		id := r.identity.ID(v)
+		switch {
+		case id != zero:
+			if _, found := items[id]; found {
+				return fmt.Errorf("%w: '%v' already exists", ErrConflict, id)
+			}
+		case r.identity.Generate == nil:
+			return fmt.Errorf("%w: an ID is required", ErrInvalid)
+		default:
+			var err error
+			if id, err = r.identity.Generate(func(id K) bool { _, found := items[id]; return found }); err != nil {
+				return err
+			}
+			r.identity.SetID(&v, id)
+		}
+		items[id] = v
+		return nil
+	})
+	return v, err
+}
+
+func (r *repository[T, K]) Update(_ context.Context, id K, v T) (T, error) {
+	r.identity.SetID(&v, id)
+	err := r.update(func(items map[K]T) error {
+		if _, found := items[id]; !found {
+			return fmt.Errorf("%w: '%v'", ErrNotFound, id)
+		}
+		items[id] = v
+		return nil
+	})
+	return v, err
+}
+
+func (r *repository[T, K]) Delete(_ context.Context, id K) error {
+	return r.update(func(items map[K]T) error {
+		if _, found := items[id]; !found {
+			return fmt.Errorf("%w: '%v'", ErrNotFound, id)
+		}
+		delete(items, id)
+		return nil
+	})
+}
+
+// update applies f to the items, persisting the result if required. The items are unchanged if f or persisting fails.
+func (r *repository[T, K]) update(f func(map[K]T) error) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.persist == nil {
+		return f(r.items)
+	}
+	items := maps.Clone(r.items)
+	if err := f(items); err != nil {
+		return err
+	}
+	if err := r.persist(r.sorted(items)); err != nil {
+		return err
+	}
+	r.items = items
+	return nil
+}
+
+// sorted returns the items in ID order.
+func (r *repository[T, K]) sorted(items map[K]T) []T {
+	res := make([]T, 0, len(items))
+	for _, id := range slices.Sorted(maps.Keys(items)) {
+		res = append(res, items[id])
+	}
+	return res
+}
+
+// writeFileAtomic writes v as JSON to a temporary file in the directory of path and renames it to path.
+func writeFileAtomic(path string, v any) (err error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(f.Name())
+		}
+	}()
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// NewRepositoryAPI creates a TypedAPIRes with the provided name serving List, Fetch, Create, Update and Delete
+// from the Repository.
+func NewRepositoryAPI[T any, K TypedID](name ResourceName, repo Repository[T, K]) *TypedAPIRes[T, K] {
+	return NewTypedAPI(name, TypedHandlers[T, K]{
+		List:   repo.List,
+		Fetch:  repo.Get,
+		Create: repo.Create,
+		Update: repo.Update,
+		Delete: repo.Delete,
+	})
+}
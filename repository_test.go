@@ -0,0 +1,263 @@
+package resweave_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mortedecai/resweave"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type note struct {
+	ID   int    `json:"id"`
+	Text string `json:"text"`
+}
+
+type tag struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type book struct {
+	ID     int      `json:"id"`
+	Title  string   `json:"title"`
+	Author string   `json:"author"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+var _ = Describe("Repositories", func() {
+	var ctx context.Context
+	noteIdentity := func() resweave.Identity[note, int] {
+		return resweave.Identity[note, int]{
+			ID:       func(n note) int { return n.ID },
+			SetID:    func(n *note, id int) { n.ID = id },
+			Generate: resweave.SequentialIDs[int](),
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	It("should require the ID and SetID functions", func() {
+		_, err := resweave.NewMemoryRepository(resweave.Identity[note, int]{ID: func(n note) int { return n.ID }})
+		Expect(err).To(MatchError(resweave.ErrInvalidIdentity))
+	})
+
+	It("should stop generating sequential IDs at the largest value of the ID type", func() {
+		none := func(int8) bool { return false }
+		gen := resweave.SequentialIDs[int8]()
+		var last int8
+		for range 127 {
+			id, err := gen(none)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(id).To(BeNumerically(">", last))
+			last = id
+		}
+		Expect(last).To(BeEquivalentTo(127))
+		_, err := gen(none)
+		Expect(err).To(MatchError(resweave.ErrConflict))
+
+		small := resweave.SequentialIDs[uint8]()
+		for range 255 {
+			_, err = small(func(uint8) bool { return false })
+		}
+		Expect(err).ToNot(HaveOccurred())
+		_, err = small(func(uint8) bool { return false })
+		Expect(err).To(MatchError(resweave.ErrConflict))
+	})
+
+	Describe("Memory", func() {
+		var repo resweave.Repository[note, int]
+		BeforeEach(func() {
+			var err error
+			repo, err = resweave.NewMemoryRepository(noteIdentity())
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should generate IDs and list in ID order", func() {
+			for _, text := range []string{"a", "b", "c"} {
+				_, err := repo.Create(ctx, note{Text: text})
+				Expect(err).ToNot(HaveOccurred())
+			}
+			n, err := repo.Create(ctx, note{ID: 10, Text: "ten"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n.ID).To(Equal(10))
+			Expect(repo.Delete(ctx, 2)).To(Succeed())
+
+			n, err = repo.Create(ctx, note{Text: "d"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n.ID).To(Equal(4))
+			all, err := repo.List(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(all).To(Equal([]note{{1, "a"}, {3, "c"}, {4, "d"}, {10, "ten"}}))
+		})
+
+		It("should return ErrNotFound and ErrConflict", func() {
+			_, err := repo.Create(ctx, note{ID: 1})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = repo.Create(ctx, note{ID: 1})
+			Expect(err).To(MatchError(resweave.ErrConflict))
+			_, err = repo.Get(ctx, 2)
+			Expect(err).To(MatchError(resweave.ErrNotFound))
+			_, err = repo.Update(ctx, 2, note{})
+			Expect(err).To(MatchError(resweave.ErrNotFound))
+			Expect(repo.Delete(ctx, 2)).To(MatchError(resweave.ErrNotFound))
+		})
+
+		It("should set the ID on Update", func() {
+			_, err := repo.Create(ctx, note{Text: "a"})
+			Expect(err).ToNot(HaveOccurred())
+			n, err := repo.Update(ctx, 1, note{ID: 5, Text: "b"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(note{1, "b"}))
+			Expect(repo.Get(ctx, 1)).To(Equal(note{1, "b"}))
+		})
+
+		It("should require an ID without a generator", func() {
+			tags, err := resweave.NewMemoryRepository(resweave.Identity[tag, string]{
+				ID:    func(t tag) string { return t.ID },
+				SetID: func(t *tag, id string) { t.ID = id },
+			})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = tags.Create(ctx, tag{Name: "go"})
+			Expect(err).To(MatchError(resweave.ErrInvalid))
+		})
+
+		It("should be safe for concurrent use", func() {
+			var wg sync.WaitGroup
+			for range 50 {
+				wg.Go(func() {
+					defer GinkgoRecover()
+					_, err := repo.Create(ctx, note{Text: "x"})
+					Expect(err).ToNot(HaveOccurred())
+					_, err = repo.List(ctx)
+					Expect(err).ToNot(HaveOccurred())
+				})
+			}
+			wg.Wait()
+			all, err := repo.List(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(all).To(HaveLen(50))
+			Expect(all[49].ID).To(Equal(50))
+		})
+	})
+
+	Describe("File", func() {
+		var path string
+		BeforeEach(func() {
+			path = filepath.Join(GinkgoT().TempDir(), "notes.json")
+		})
+
+		It("should persist changes and load them when reopened", func() {
+			repo, err := resweave.NewFileRepository(path, noteIdentity())
+			Expect(err).ToNot(HaveOccurred())
+			_, err = repo.Create(ctx, note{Text: "a"})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = repo.Create(ctx, note{Text: "b"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(repo.Delete(ctx, 1)).To(Succeed())
+			Expect(os.ReadFile(path)).To(MatchJSON(`[{"id":2,"text":"b"}]`))
+
+			reopened, err := resweave.NewFileRepository(path, noteIdentity())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reopened.List(ctx)).To(Equal([]note{{2, "b"}}))
+			n, err := reopened.Create(ctx, note{Text: "c"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n.ID).To(Equal(1))
+
+			entries, err := os.ReadDir(filepath.Dir(path))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(HaveLen(1))
+		})
+
+		It("should discard a change which cannot be written", func() {
+			repo, err := resweave.NewFileRepository(path, noteIdentity())
+			Expect(err).ToNot(HaveOccurred())
+			_, err = repo.Create(ctx, note{Text: "a"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(os.Remove(path)).To(Succeed())
+			Expect(os.Mkdir(path, 0o700)).To(Succeed())
+			_, err = repo.Create(ctx, note{Text: "b"})
+			Expect(err).To(HaveOccurred())
+			Expect(repo.List(ctx)).To(HaveLen(1))
+		})
+
+		It("should fail to open a file which is not a JSON array", func() {
+			Expect(os.WriteFile(path, []byte(`{`), 0o600)).To(Succeed())
+			_, err := resweave.NewFileRepository(path, noteIdentity())
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("NewRepositoryAPI", func() {
+		It("should serve CRUD endpoints from the repository", func() {
+			repo, err := resweave.NewMemoryRepository(resweave.Identity[tag, string]{
+				ID:       func(t tag) string { return t.ID },
+				SetID:    func(t *tag, id string) { t.ID = id },
+				Generate: resweave.UUIDs[string](),
+			})
+			Expect(err).ToNot(HaveOccurred())
+			s := resweave.NewServer(0)
+			Expect(s.AddResource(resweave.NewRepositoryAPI("tags", repo))).To(Succeed())
+			do := func(method string, target string, body string) *httptest.ResponseRecorder {
+				recorder := httptest.NewRecorder()
+				s.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+				return recorder
+			}
+
+			Expect(do(http.MethodPost, "/tags", `{"name":"go"}`).Code).To(Equal(http.StatusCreated))
+			all, err := repo.List(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(all).To(HaveLen(1))
+			id := all[0].ID
+			Expect(id).To(MatchRegexp(`^[0-9a-f]{8}-[0-9a-f]{4}-7`))
+
+			rec := do(http.MethodGet, "/tags/"+id, "")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(MatchJSON(`{"id":"` + id + `","name":"go"}`))
+			Expect(do(http.MethodPut, "/tags/"+id, `{"name":"golang"}`).Code).To(Equal(http.StatusOK))
+			Expect(do(http.MethodGet, "/tags", "").Body.String()).To(MatchJSON(`[{"id":"` + id + `","name":"golang"}]`))
+			Expect(do(http.MethodDelete, "/tags/"+id, "").Code).To(Equal(http.StatusNoContent))
+			Expect(do(http.MethodGet, "/tags/"+id, "").Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("should apply PATCH as a JSON merge patch, keeping the fields it does not mention", func() {
+			repo, err := resweave.NewMemoryRepository(resweave.Identity[book, int]{
+				ID:       func(b book) int { return b.ID },
+				SetID:    func(b *book, id int) { b.ID = id },
+				Generate: resweave.SequentialIDs[int](),
+			})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = repo.Create(ctx, book{Title: "Dune", Author: "Herbert", Tags: []string{"sf"}})
+			Expect(err).ToNot(HaveOccurred())
+			s := resweave.NewServer(0)
+			Expect(s.AddResource(resweave.NewRepositoryAPI("books", repo))).To(Succeed())
+			patch := func(target string, body string) *httptest.ResponseRecorder {
+				recorder := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodPatch, target, strings.NewReader(body))
+				req.Header.Set("Content-Type", "application/merge-patch+json")
+				s.ServeHTTP(recorder, req)
+				return recorder
+			}
+
+			rec := patch("/books/1", `{"title":"Dune Messiah","tags":null}`)
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(MatchJSON(`{"id":1,"title":"Dune Messiah","author":"Herbert"}`))
+			b, err := repo.Get(ctx, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal(book{ID: 1, Title: "Dune Messiah", Author: "Herbert"}))
+
+			Expect(patch("/books/2", `{"title":"Emma"}`).Code).To(Equal(http.StatusNotFound))
+			Expect(patch("/books/1", `{"title":7}`).Code).To(Equal(http.StatusBadRequest))
+			Expect(patch("/books/1", `{"title":`).Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
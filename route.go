@@ -0,0 +1,269 @@
+package resweave
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// ResourceKind identifies the type of Resource serving a Route.
+type ResourceKind int
+
+const (
+	// KindOther is a Resource which is neither an APIResource nor an HTMLResource.
+	KindOther ResourceKind = iota
+	// KindAPI is an APIResource.
+	KindAPI
+	// KindHTML is an HTMLResource.
+	KindHTML
+)
+
+func (k ResourceKind) String() string {
+	switch k {
+	case KindAPI:
+		return "API"
+	case KindHTML:
+		return "HTML"
+	default:
+		return "Other"
+	}
+}
+
+// RouteParam is a path parameter of a Route, e.g. users_id in /users/{users_id}.
+type RouteParam struct {
+	// Name is the name of the parameter as it appears in the path template.
+	Name string
+	// ID is the regex the parameter value must match.
+	ID ID
+}
+
+// Route describes a single path served by a Server.
+type Route struct {
+	// Host is the name of the Host serving the route; empty for the default Host.
+	Host HostName
+	// Path is the path template, e.g. /users/{users_id}/profile. HTML resources end with /* to indicate any file path.
+	Path string
+	// Params are the path parameters in Path, in order.
+	Params []RouteParam
+	// Kind is the kind of resource serving the route.
+	Kind ResourceKind
+	// Actions are the actions with handlers set at this path.
+	Actions []ActionType
+	// Methods are the HTTP methods accepted for Actions.
+	Methods []string
+	// Resource is the resource serving the route.
+	Resource Resource
+}
+
+// actionName returns the name of the action, using the name of a custom action of the route's resource.
+func (r Route) actionName(at ActionType) string {
+	if res, ok := r.Resource.(APIResource); ok && at.IsCustom() {
+		if ca, found := res.CustomActionFor(at); found {
+			return ca.Name
+		}
+	}
+	return at.String()
+}
+
+// RouteTable is the list of Routes served by a Server or Host.
+type RouteTable []Route
+
+// String renders the RouteTable as an aligned table, e.g. for logging at startup.
+func (rt RouteTable) String() string {
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "HOST\tPATH\tKIND\tMETHODS\tACTIONS\tPARAMS")
+	for _, r := range rt {
+		host := string(r.Host)
+		if host == "" {
+			host = "(default)"
+		}
+		actions := make([]string, len(r.Actions))
+		for i, at := range r.Actions {
+			actions[i] = r.actionName(at)
+		}
+		params := make([]string, len(r.Params))
+		for i, p := range r.Params {
+			params[i] = fmt.Sprintf("%s=%s", p.Name, p.ID)
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", host, r.Path, r.Kind,
+			strings.Join(r.Methods, ","), strings.Join(actions, ","), strings.Join(params, " "))
+	}
+	_ = tw.Flush()
+	return sb.String()
+}
+
+// collectionActions are the actions served without an ID in the path and instanceActions those requiring one.
+// Other actions are served on both paths.
+var (
+	collectionActions = []ActionType{List, Create}
+	instanceActions   = []ActionType{Fetch, Update, Patch, Delete}
+)
+
+// methods returns the HTTP methods which are mapped to the action.
+func (at ActionType) methods() []string {
+	switch at {
+	case Create:
+		return []string{http.MethodPost}
+	case List, Fetch:
+		return []string{http.MethodGet}
+	case Update:
+		// PATCH falls back to Update when no Patch handler is set.
+		return []string{http.MethodPut, http.MethodPatch}
+	case Patch:
+		return []string{http.MethodPatch}
+	case Delete:
+		return []string{http.MethodDelete}
+	case Head:
+		return []string{http.MethodHead}
+	case Options:
+		return []string{http.MethodOptions}
+	default:
+		return nil
+	}
+}
+
+// actionMethods returns the sorted, de-duplicated HTTP methods for the actions.
+func actionMethods(actions []ActionType) []string {
+	var methods []string
+	for _, at := range actions {
+		methods = append(methods, at.methods()...)
+	}
+	slices.Sort(methods)
+	return slices.Compact(methods)
+}
+
+// routeMethods returns the HTTP methods served for the actions of an API resource path, including HEAD if GET is
+// served and OPTIONS, which are answered automatically.
+func routeMethods(actions []ActionType) []string {
+	methods := actionMethods(actions)
+	if slices.Contains(methods, http.MethodGet) {
+		methods = append(methods, http.MethodHead)
+	}
+	methods = append(methods, http.MethodOptions)
+	slices.Sort(methods)
+	return slices.Compact(methods)
+}
+
+// idParamName returns the name of the path parameter for the ID of the named resource.
+func idParamName(name ResourceName) string {
+	if name == "" {
+		return "id"
+	}
+	return name.String() + "_id"
+}
+
+// routeBuilder walks a resource tree, collecting the Routes for a single Host.
+type routeBuilder struct {
+	host   HostName
+	routes RouteTable
+}
+
+func (rb *routeBuilder) joinPath(prefix string, segment string) string {
+	if segment == "" {
+		return prefix
+	}
+	return prefix + "/" + segment
+}
+
+func (rb *routeBuilder) add(path string, params []RouteParam, kind ResourceKind, actions []ActionType, r Resource) {
+	methods := actionMethods(actions)
+	switch kind {
+	case KindAPI:
+		methods = routeMethods(actions)
+		if pr, ok := r.(patchResource); ok {
+			methods = pr.servedMethods(actions, methods)
+		}
+	case KindHTML:
+		methods = []string{http.MethodGet, http.MethodHead}
+	}
+	rb.addRoute(path, params, kind, actions, methods, r)
+}
+
+// addAction adds the route for a custom action of an API resource.
+func (rb *routeBuilder) addAction(path string, params []RouteParam, ca CustomAction, r APIResource) {
+	for _, at := range r.Actions() {
+		if found, ok := r.CustomActionFor(at); ok && found.Name == ca.Name {
+			rb.addRoute(path, params, KindAPI, []ActionType{at}, ca.methods(), r)
+			return
+		}
+	}
+}
+
+func (rb *routeBuilder) addRoute(path string, params []RouteParam, kind ResourceKind, actions []ActionType, methods []string, r Resource) {
+	if path == "" {
+		path = "/"
+	}
+	rb.routes = append(rb.routes, Route{
+		Host:     rb.host,
+		Path:     path,
+		Params:   slices.Clip(params),
+		Kind:     kind,
+		Actions:  actions,
+		Methods:  methods,
+		Resource: r,
+	})
+}
+
+func (rb *routeBuilder) walk(prefix string, params []RouteParam, r Resource) {
+	path := rb.joinPath(prefix, r.Name().String())
+	switch res := r.(type) {
+	case APIResource:
+		var collection, instance []ActionType
+		hasInstance := false
+		for _, at := range res.Actions() {
+			if at.IsCustom() {
+				continue
+			}
+			if !slices.Contains(instanceActions, at) {
+				collection = append(collection, at)
+			}
+			if !slices.Contains(collectionActions, at) {
+				instance = append(instance, at)
+				hasInstance = hasInstance || slices.Contains(instanceActions, at)
+			}
+		}
+		rb.add(path, params, KindAPI, collection, r)
+		actions := res.CustomActions()
+		for _, ca := range actions {
+			if ca.Scope == CollectionScope {
+				rb.addAction(path+"/"+actionsSegment+"/"+ca.Name, params, ca, res)
+			}
+		}
+		for _, sub := range res.Resources() {
+			rb.walk(path, params, sub)
+		}
+
+		idParams := append(slices.Clip(params), RouteParam{Name: idParamName(r.Name()), ID: res.GetID()})
+		idPath := rb.joinPath(path, "{"+idParamName(r.Name())+"}")
+		if hasInstance {
+			rb.add(idPath, idParams, KindAPI, instance, r)
+		}
+		for _, ca := range actions {
+			if ca.Scope == InstanceScope {
+				rb.addAction(idPath+actionSeparator+ca.Name, idParams, ca, res)
+			}
+		}
+		for _, child := range res.ChildResources() {
+			rb.walk(idPath, idParams, child)
+		}
+	case HTMLResource:
+		rb.add(path+"/*", params, KindHTML, []ActionType{Fetch}, r)
+	default:
+		rb.add(path, params, KindOther, nil, r)
+	}
+}
+
+// sortedResources returns the resources of the map sorted by name.
+func sortedResources(resources map[ResourceName]Resource) []Resource {
+	res := make([]Resource, 0, len(resources))
+	for _, r := range resources {
+		res = append(res, r)
+	}
+	slices.SortFunc(res, func(a, b Resource) int {
+		return strings.Compare(a.Name().String(), b.Name().String())
+	})
+	return res
+}
@@ -0,0 +1,116 @@
+package resweave_test
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/mortedecai/resweave"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Routes", func() {
+	var (
+		s     resweave.Server
+		users resweave.APIResource
+	)
+	ok := func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	paths := func(rt resweave.RouteTable) []string {
+		p := make([]string, len(rt))
+		for i, r := range rt {
+			p[i] = string(r.Host) + r.Path
+		}
+		return p
+	}
+
+	BeforeEach(func() {
+		s = resweave.NewServer(0)
+
+		users = resweave.NewAPI("users")
+		users.SetList(ok)
+		users.SetCreate(ok)
+		users.SetFetch(ok)
+		users.SetDelete(ok)
+		search := resweave.NewAPI("search")
+		search.SetList(ok)
+		profile := resweave.NewAPI("profile")
+		profile.SetUpdate(ok)
+		Expect(profile.SetID(resweave.UUIDv7)).To(Succeed())
+		emails := resweave.NewAPI("emails")
+		emails.SetList(ok)
+		emails.SetDelete(ok)
+		Expect(profile.AddChildResource(emails)).To(Succeed())
+		Expect(users.AddResource(search)).To(Succeed())
+		Expect(users.AddChildResource(profile)).To(Succeed())
+		Expect(s.AddResource(users)).To(Succeed())
+
+		h, err := s.AddHost("static.example.com")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(h.AddResource(resweave.NewHTML("assets", "."))).To(Succeed())
+	})
+
+	It("should expose the actions, ID and sub-resources of an APIResource", func() {
+		Expect(users.Actions()).To(Equal([]resweave.ActionType{resweave.Create, resweave.List, resweave.Fetch, resweave.Delete}))
+		Expect(users.GetID()).To(Equal(resweave.NumericID))
+		Expect(users.Resources()).To(HaveLen(1))
+		Expect(users.Resources()[0].Name()).To(Equal(resweave.ResourceName("search")))
+		Expect(users.ChildResources()).To(HaveLen(1))
+		Expect(users.ChildResources()[0].Name()).To(Equal(resweave.ResourceName("profile")))
+		users.SetList(nil)
+		Expect(users.Actions()).ToNot(ContainElement(resweave.List))
+	})
+
+	It("should walk every host, resource, sub-resource and child resource", func() {
+		Expect(paths(s.Routes())).To(Equal([]string{
+			"/users",
+			"/users/search",
+			"/users/{users_id}",
+			"/users/{users_id}/profile",
+			"/users/{users_id}/profile/{profile_id}",
+			"/users/{users_id}/profile/{profile_id}/emails",
+			"/users/{users_id}/profile/{profile_id}/emails/{emails_id}",
+			"static.example.com/assets/*",
+		}))
+	})
+
+	It("should describe the actions, methods, parameters and kind of each route", func() {
+		rt := s.Routes()
+
+		Expect(rt[0].Kind).To(Equal(resweave.KindAPI))
+		Expect(rt[0].Actions).To(Equal([]resweave.ActionType{resweave.Create, resweave.List}))
+		Expect(rt[0].Methods).To(Equal([]string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPost}))
+		Expect(rt[0].Params).To(BeEmpty())
+		Expect(rt[0].Resource).To(Equal(users))
+
+		Expect(rt[2].Actions).To(Equal([]resweave.ActionType{resweave.Fetch, resweave.Delete}))
+		Expect(rt[2].Methods).To(Equal([]string{http.MethodDelete, http.MethodGet, http.MethodHead, http.MethodOptions}))
+		Expect(rt[2].Params).To(Equal([]resweave.RouteParam{{Name: "users_id", ID: resweave.NumericID}}))
+
+		Expect(rt[4].Methods).To(Equal([]string{http.MethodOptions, http.MethodPatch, http.MethodPut}))
+		Expect(rt[4].Params).To(Equal([]resweave.RouteParam{
+			{Name: "users_id", ID: resweave.NumericID},
+			{Name: "profile_id", ID: resweave.UUIDv7},
+		}))
+
+		Expect(rt[7].Host).To(Equal(resweave.HostName("static.example.com")))
+		Expect(rt[7].Kind).To(Equal(resweave.KindHTML))
+		Expect(rt[7].Methods).To(Equal([]string{http.MethodGet, http.MethodHead}))
+	})
+
+	It("should reflect runtime changes", func() {
+		_, err := users.RemoveChildResource("profile")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = s.RemoveHost("static.example.com")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(paths(s.Routes())).To(Equal([]string{"/users", "/users/search", "/users/{users_id}"}))
+	})
+
+	It("should render as a table", func() {
+		out := s.Routes().String()
+		Expect(out).To(HavePrefix("HOST"))
+		Expect(out).To(MatchRegexp(`\(default\)\s+/users/\{users_id\}\s+API\s+DELETE,GET,HEAD,OPTIONS\s+Fetch,Delete\s+users_id=\(\[0-9\]\+\)`))
+		Expect(out).To(MatchRegexp(`static\.example\.com\s+/assets/\*\s+HTML\s+GET,HEAD\s+Fetch`))
+	})
+})
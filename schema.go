@@ -0,0 +1,161 @@
+package resweave
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const schemaRefPrefix = "#/components/schemas/"
+
+var (
+	typeTime          = reflect.TypeFor[time.Time]()
+	typeJSONMarshaler = reflect.TypeFor[json.Marshaler]()
+	typeTextMarshaler = reflect.TypeFor[encoding.TextMarshaler]()
+	// schemaNameInvalid matches the characters not allowed in a component name, e.g. the brackets of generic types.
+	schemaNameInvalid = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// JSONSchema is a JSON Schema (draft 2020-12), as used by OpenAPI 3.1.
+type JSONSchema map[string]any
+
+// schemaBuilder builds the JSON Schemas for Go types as encoding/json encodes them. Named struct types are added to
+// the schemas, keyed by their type name, and referenced from the schemas using them.
+type schemaBuilder struct {
+	schemas map[string]JSONSchema
+	names   map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{schemas: make(map[string]JSONSchema), names: make(map[reflect.Type]string)}
+}
+
+// schema returns the schema for t, or a reference to it for named struct types.
+func (sb *schemaBuilder) schema(t reflect.Type) JSONSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == typeTime:
+		return JSONSchema{"type": "string", "format": "date-time"}
+	case t.Implements(typeJSONMarshaler) || reflect.PointerTo(t).Implements(typeJSONMarshaler):
+		return JSONSchema{}
+	case t.Implements(typeTextMarshaler) || reflect.PointerTo(t).Implements(typeTextMarshaler):
+		return JSONSchema{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return JSONSchema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return JSONSchema{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return JSONSchema{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return JSONSchema{"type": "number"}
+	case reflect.String:
+		return JSONSchema{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return JSONSchema{"type": "string", "contentEncoding": "base64"}
+		}
+		return JSONSchema{"type": "array", "items": sb.schema(t.Elem())}
+	case reflect.Array:
+		return JSONSchema{"type": "array", "items": sb.schema(t.Elem()), "minItems": t.Len(), "maxItems": t.Len()}
+	case reflect.Map:
+		return JSONSchema{"type": "object", "additionalProperties": sb.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return sb.object(t)
+		}
+		return sb.ref(t)
+	default:
+		return JSONSchema{}
+	}
+}
+
+// ref adds the schema for the named struct type t, if it has not been added, and returns a reference to it.
+func (sb *schemaBuilder) ref(t reflect.Type) JSONSchema {
+	name, found := sb.names[t]
+	if !found {
+		name = sb.name(t)
+		sb.names[t] = name
+		// Reserve the name before building the schema, which may refer to t.
+		sb.schemas[name] = JSONSchema{}
+		sb.schemas[name] = sb.object(t)
+	}
+	return JSONSchema{"$ref": schemaRefPrefix + name}
+}
+
+// name returns an unused component name for t.
+func (sb *schemaBuilder) name(t reflect.Type) string {
+	base := strings.Trim(schemaNameInvalid.ReplaceAllString(t.Name(), "_"), "_")
+	name := base
+	for i := 2; ; i++ {
+		if _, taken := sb.schemas[name]; !taken {
+			return name
+		}
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+}
+
+// object returns the schema for the struct type t. Fields which are always encoded, without omitempty or omitzero,
+// are required.
+func (sb *schemaBuilder) object(t reflect.Type) JSONSchema {
+	properties := make(map[string]any)
+	var required []string
+	sb.fields(t, properties, &required)
+	s := JSONSchema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// fields adds the properties for the exported fields of t, including those of embedded structs.
+func (sb *schemaBuilder) fields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			sb.fields(ft, properties, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		var s JSONSchema
+		if hasTagOption(opts, "string") {
+			s = JSONSchema{"type": "string"}
+		} else {
+			s = sb.schema(f.Type)
+		}
+		properties[name] = s
+		if !hasTagOption(opts, "omitempty") && !hasTagOption(opts, "omitzero") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func hasTagOption(opts string, option string) bool {
+	for opt := range strings.SplitSeq(opts, ",") {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
@@ -2,8 +2,16 @@ package resweave
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,32 +29,90 @@ type Interceptor func(http.Handler) http.Handler
 //
 // This also applies to HTML resources. For example, `v1/html/somedir/index.html`, would require a `v1` path prefix,
 // and an HTML resource `html`, with a top level directory `somedir` containing an `index.html` file.
+//
+// A Server is an http.Handler, so it may be mounted in any http.Server, httptest.Server or mux instead of being run
+// with Run or RunContext.
 type Server interface {
+	// ServeHTTP assigns the request ID and passes the request through the interceptor chain to the matching Host.
+	http.Handler
 	// AddHost adds a new Host to the Server instance with the Host being returned on success.
 	// On error, Host will be nil and the relevant error will be returned.
+	//
+	// Exact host names are normalized (see HostName.Normalize), so Example.COM. and example.com name the same Host.
+	// Besides exact host names, name may be a wildcard such as *.app.example.com, matching one or more labels in
+	// front of app.example.com, or a regular expression prefixed with ~, e.g. ~([a-z]+)\.example\.(com|org), which must
+	// match the whole host name. Exact names take precedence, then wildcards (longest first), then regular expressions
+	// in the order added. The matched labels are available to handlers via GetHostLabels.
+	// An invalid pattern returns an error wrapping ErrInvalidHostPattern.
 	AddHost(name HostName) (Host, error)
 	// GetHost finds an existing Host in the Server instance with the Host being returned on success.
 	// On error, Host will be nil and the boolean will be false.
 	GetHost(name HostName) (Host, bool)
+	// AddHostAlias registers the existing Host named name under the additional name alias, sharing its resources.
+	// The alias may be a wildcard or regex pattern as for AddHost. An error wrapping ErrHostNotFound is returned if no
+	// Host is registered under name, and an error if alias is already in use or an invalid pattern.
+	AddHostAlias(name HostName, alias HostName) error
+	// RemoveHost removes a named Host from the Server instance, returning the removed Host.
+	// Requests for the removed name are subsequently served by the default Host.
+	// Removing a Host by its own name also removes its aliases; removing an alias only removes that alias.
+	// An error wrapping ErrHostNotFound is returned if no such Host exists, and ErrDefaultHostRemoval if the
+	// default Host is named.
+	RemoveHost(name HostName) (Host, error)
 	// AddResource adds the provided resource to the Server instance.
 	// If the resource is nil or can otherwise not be added to the Server, an error will be returned.
 	AddResource(r Resource) error
 	// GetResource retrieves a Resource by name from the resources at the root level of this node.
 	// If the provided name cannot be found, the returned resource will be nil and the boolean will be false.
 	GetResource(name ResourceName) (res Resource, found bool)
+	// RemoveResource removes a top level resource from the default Host; see Host.RemoveResource.
+	RemoveResource(name ResourceName) (Resource, error)
+	// ReplaceResource replaces a top level resource on the default Host; see Host.ReplaceResource.
+	ReplaceResource(r Resource) (Resource, error)
+	// Routes returns the routes served by every Host, ordered by host name with the default Host first.
+	// The returned RouteTable renders as a table when printed, e.g. for logging at startup.
+	Routes() RouteTable
 	// Run runs the actual server and will return an error on failure.
+	// It is equivalent to RunContext(context.Background()).
 	Run() error
+	// RunContext runs the server until the provided context is done, at which point the server is gracefully shut down
+	// within the shutdown timeout (see SetShutdownTimeout).
+	// A graceful shutdown returns nil; otherwise the error which stopped the server is returned.
+	RunContext(ctx context.Context) error
+	// Shutdown stops the server from accepting new connections, waits for active requests to complete and then
+	// runs the shutdown hooks registered on the hosts and resources.
+	// If ctx is done before the active requests complete, an error wrapping ErrShutdownDeadlineExceeded is returned.
+	// When calling Shutdown directly, callers should wait for it to return before exiting, as Run and RunContext
+	// return as soon as the server stops accepting connections.
+	Shutdown(ctx context.Context) error
+	// SetShutdownTimeout sets the time RunContext allows for a graceful shutdown once its context is done.
+	SetShutdownTimeout(d time.Duration)
 	// Port returns the port number the server will run on.
+	// While running, this is the port of the first TCP listener actually bound, which differs from the configured
+	// port when port 0 is used.
 	Port() int
+	// Addrs returns the addresses of the listeners the server is currently accepting connections on.
+	Addrs() []net.Addr
 	// Sets the logger to use for the server, and if recursive is true, to each of the hosts and resources.
 	SetLogger(logger *zap.SugaredLogger, recursive bool)
+	// SetNotFoundHandler sets the function responding to requests for unknown resources and sub-resources.
+	// By default a 404 problem details response (application/problem+json) is written with WriteError.
+	// Hosts and resources may override it.
+	SetNotFoundHandler(f ResweaveFunc)
+	// SetMethodNotAllowedHandler sets the function responding to requests for actions without a handler.
+	// The Allow header has already been set when it is called. By default a 405 problem details response
+	// (application/problem+json) is written with WriteError.
+	// Hosts and resources may override it.
+	SetMethodNotAllowedHandler(f ResweaveFunc)
 	// AddInterceptor adds a new interceptor at the start of the handling chain.
 	// For example, on an incoming request, _next_ will be called first, with any current interceptors being
+	// called after it.
+	// Server interceptors run before those added to a Host, which run before those added to a Resource.
 	AddInterceptor(Interceptor)
 }
 
 const (
-	defaultHostName = HostName("")
+	defaultHostName        = HostName("")
+	defaultShutdownTimeout = 30 * time.Second
 )
 
 // NewServer creates a new instance of a resweave Server.
@@ -57,23 +123,50 @@ const (
 // Parameters:
 //
 // * port: The port number to run the server on
-func NewServer(port int) Server {
-	s := &server{port: port, hosts: make(HostMap)}
-	s.hosts[defaultHostName] = newHost(defaultHostName)
+// * opts: Optional ServerOptions, e.g. WithTLSFiles(...) to serve HTTPS
+func NewServer(port int, opts ...ServerOption) Server {
+	s := &server{
+		port:            port,
+		shutdownTimeout: defaultShutdownTimeout,
+		httpOpts:        httpOptions{readHeaderTimeout: defaultReadHeaderTimeout},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	_ = s.hosts.update(func(hosts map[HostName]Host) error {
+		hosts[defaultHostName] = newHost(defaultHostName)
+		return nil
+	})
 	s.LogHolder = NewLogholder("<srv>", s.recurse)
-	s.interceptor = http.HandlerFunc(s.serve)
 	return s
 }
 
 type server struct {
-	port        int
-	hosts       HostMap
-	interceptor http.Handler
+	port            int
+	hosts           routeTable[HostName, Host]
+	hostPatterns    atomic.Pointer[[]*hostPattern]
+	trustedProxies  []netip.Prefix
+	interceptors    interceptorChain
+	fallback        fallbackHandlers
+	errorMappings   []errorMapping
+	shutdownTimeout time.Duration
+	httpServer      *http.Server
+	redirectServer  *http.Server
+	listeners       []net.Listener
+	listenFuncs     []ListenFunc
+	httpOpts        httpOptions
+	mtx             sync.Mutex
+
+	tlsConfig    *tls.Config
+	certFile     string
+	keyFile      string
+	redirectPort int
+	hsts         string
 	LogHolder
 }
 
 func (s *server) recurse(l *zap.SugaredLogger) {
-	for _, h := range s.hosts {
+	for _, h := range s.hostList() {
 		h.SetLogger(l, true)
 	}
 }
@@ -83,38 +176,185 @@ func (s *server) getRunAddr() string {
 }
 
 func (s *server) Port() int {
+	for _, addr := range s.Addrs() {
+		if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+			return tcpAddr.Port
+		}
+	}
 	return s.port
 }
 
+func (s *server) Addrs() []net.Addr {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	addrs := make([]net.Addr, len(s.listeners))
+	for i, l := range s.listeners {
+		addrs[i] = l.Addr()
+	}
+	return addrs
+}
+
 func (s *server) createHTTPServer() *http.Server {
 	s.Infow("createHTTPServer", "Address", s.getRunAddr())
-	return &http.Server{
+	srv := &http.Server{
 		Addr:              s.getRunAddr(),
-		ReadHeaderTimeout: 3 * time.Second,
+		Handler:           s,
+		ReadTimeout:       s.httpOpts.readTimeout,
+		ReadHeaderTimeout: s.httpOpts.readHeaderTimeout,
+		WriteTimeout:      s.httpOpts.writeTimeout,
+		IdleTimeout:       s.httpOpts.idleTimeout,
+		MaxHeaderBytes:    s.httpOpts.maxHeaderBytes,
+		ConnState:         s.httpOpts.connState(),
+		Protocols:         s.httpOpts.protocols(),
+		HTTP2:             s.httpOpts.http2,
 	}
+	srv.SetKeepAlivesEnabled(!s.httpOpts.disableKeepAlives)
+	return srv
 }
 
 func (s *server) getDefaultHost() Host {
-	return s.hosts[defaultHostName]
+	h, _ := s.GetHost(defaultHostName)
+	return h
+}
+
+// hostList returns the Hosts of the Server, including the default Host. Hosts registered under aliases are only
+// returned once.
+func (s *server) hostList() []Host {
+	hosts := make([]Host, 0, s.hosts.len())
+	for name, h := range s.hosts.load() {
+		if h.Name() == name {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
 }
 
 func (s *server) serve(w http.ResponseWriter, req *http.Request) {
 	s.Infow("serve", "Request URI", req.URL, "Host", req.Host, "Header", req.Header)
-	var host Host = s.getDefaultHost()
-	hostname := HostName(req.Host)
+	req = s.resolveForwardedHost(req)
+	hostname := HostName(req.Host).Normalize()
 
-	if h, f := s.hosts[hostname.StripPort()]; f {
-		s.Infow("serve", "Hostname", hostname.StripPort(), "Found?", true, "Default?", false)
-		host = h
-	} else {
-		s.Infow("serve", "Hostname", hostname.StripPort(), "Found?", false, "Default?", true)
+	host, labels, found := s.matchHost(hostname)
+	s.Infow("serve", "Hostname", hostname, "Found?", found, "Default?", !found, "Labels", labels)
+	if !found {
+		host = s.getDefaultHost()
 	}
+	ctx := s.fallback.withContext(req.Context())
+	if len(labels) > 0 {
+		ctx = context.WithValue(ctx, KeyHostLabels, labels)
+	}
+	req = req.WithContext(ctx)
 	host.Serve(w, req)
 }
 
+func (s *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if s.hsts != "" && req.TLS != nil {
+		w.Header().Set("Strict-Transport-Security", s.hsts)
+	}
+	if len(s.errorMappings) > 0 {
+		// Added before the interceptors run, so errors they write are mapped too.
+		req = req.WithContext(withErrorMappings(req.Context(), s.errorMappings))
+	}
+	s.setRequestIDInterceptor(s.chain()).ServeHTTP(w, req)
+}
+
 func (s *server) Run() error {
-	http.Handle("/", s.setRequestIDInterceptor(s.interceptor))
-	return s.createHTTPServer().ListenAndServe()
+	return s.RunContext(context.Background())
+}
+
+func (s *server) RunContext(ctx context.Context) error {
+	srv := s.createHTTPServer()
+	useTLS := s.tlsEnabled()
+	if useTLS {
+		cfg, err := s.buildTLSConfig()
+		if err != nil {
+			s.Errorw("RunContext", "TLS Error", err)
+			return err
+		}
+		srv.TLSConfig = cfg
+	}
+	listeners, err := s.openListeners()
+	if err != nil {
+		s.Errorw("RunContext", "Listen Error", err)
+		return err
+	}
+	var redirect *http.Server
+	if useTLS && s.redirectPort > 0 {
+		redirect = s.createRedirectServer()
+	}
+	s.mtx.Lock()
+	s.httpServer = srv
+	s.redirectServer = redirect
+	s.listeners = listeners
+	s.mtx.Unlock()
+	defer func() {
+		s.mtx.Lock()
+		s.listeners = nil
+		s.mtx.Unlock()
+	}()
+
+	errCh := make(chan error, len(listeners)+1)
+	for _, l := range listeners {
+		s.Infow("RunContext", "Network", l.Addr().Network(), "Address", l.Addr().String(), "TLS", useTLS)
+		go func(l net.Listener) {
+			if useTLS {
+				// Certificates are supplied through srv.TLSConfig.
+				errCh <- srv.ServeTLS(l, "", "")
+				return
+			}
+			errCh <- srv.Serve(l)
+		}(l)
+	}
+	if redirect != nil {
+		go func() {
+			errCh <- redirect.ListenAndServe()
+		}()
+	}
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		// One listener failed; do not leave the other running.
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+		defer cancel()
+		return errors.Join(err, s.Shutdown(shutdownCtx))
+	case <-ctx.Done():
+		s.Infow("RunContext", "Context Done", ctx.Err(), "Shutdown Timeout", s.shutdownTimeout)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+		defer cancel()
+		return s.Shutdown(shutdownCtx)
+	}
+}
+
+func (s *server) Shutdown(ctx context.Context) error {
+	s.mtx.Lock()
+	httpServers := []*http.Server{s.httpServer, s.redirectServer}
+	s.mtx.Unlock()
+
+	var errs []error
+	for _, srv := range httpServers {
+		if srv == nil {
+			continue
+		}
+		if err := srv.Shutdown(ctx); err != nil {
+			s.Errorw("Shutdown", "Address", srv.Addr, "Error", err)
+			if errors.Is(err, context.DeadlineExceeded) {
+				err = fmt.Errorf("%w: %w", ErrShutdownDeadlineExceeded, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	for _, h := range s.hostList() {
+		errs = append(errs, h.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *server) SetShutdownTimeout(d time.Duration) {
+	s.shutdownTimeout = d
 }
 
 func (s *server) AddResource(r Resource) error {
@@ -125,28 +365,156 @@ func (s *server) GetResource(name ResourceName) (Resource, bool) {
 	return s.getDefaultHost().GetResource(name)
 }
 
+func (s *server) RemoveResource(name ResourceName) (Resource, error) {
+	return s.getDefaultHost().RemoveResource(name)
+}
+
+func (s *server) ReplaceResource(r Resource) (Resource, error) {
+	return s.getDefaultHost().ReplaceResource(r)
+}
+
 func (s *server) AddHost(name HostName) (Host, error) {
-	if _, found := s.hosts[name]; found {
-		return nil, fmt.Errorf("host '%s' already exists", name)
-	}
+	name = normalizeHostName(name)
 	h := newHost(name)
-	s.hosts[name] = h
+	pattern, err := newHostPattern(name, h)
+	if err != nil {
+		s.Infow("AddHost", "Name", name, "Error", err)
+		return nil, err
+	}
+	err = s.hosts.update(func(hosts map[HostName]Host) error {
+		if _, found := hosts[name]; found {
+			return fmt.Errorf("host '%s' already exists", name)
+		}
+		hosts[name] = h
+		if pattern != nil {
+			s.updateHostPatterns(func(patterns []*hostPattern) []*hostPattern {
+				return append(patterns, pattern)
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 	return h, nil
 }
 
+func (s *server) Routes() RouteTable {
+	hosts := s.hostList()
+	slices.SortFunc(hosts, func(a, b Host) int {
+		return strings.Compare(string(a.Name()), string(b.Name()))
+	})
+	var routes RouteTable
+	for _, h := range hosts {
+		routes = append(routes, h.Routes()...)
+	}
+	return routes
+}
+
 func (s *server) GetHost(name HostName) (h Host, f bool) {
-	h, f = s.hosts[name]
-	return
+	return s.hosts.get(normalizeHostName(name))
+}
+
+func (s *server) RemoveHost(name HostName) (Host, error) {
+	name = normalizeHostName(name)
+	if name == defaultHostName {
+		return nil, ErrDefaultHostRemoval
+	}
+	var h Host
+	err := s.hosts.update(func(hosts map[HostName]Host) error {
+		var found bool
+		if h, found = hosts[name]; !found {
+			s.Infow("RemoveHost", "Name", name, "Exists?", found)
+			return fmt.Errorf(fmtHostNotFound, ErrHostNotFound, name)
+		}
+		removed := []HostName{name}
+		if h.Name() == name {
+			// Removing the Host itself also removes all of its aliases.
+			for n, other := range hosts {
+				if other == h && n != name {
+					removed = append(removed, n)
+				}
+			}
+		}
+		for _, n := range removed {
+			delete(hosts, n)
+		}
+		s.updateHostPatterns(func(patterns []*hostPattern) []*hostPattern {
+			return slices.DeleteFunc(patterns, func(p *hostPattern) bool { return slices.Contains(removed, p.name) })
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Infow("RemoveHost", "Name", name, "Removed", true)
+	return h, nil
+}
+
+func (s *server) AddHostAlias(name HostName, alias HostName) error {
+	name, alias = normalizeHostName(name), normalizeHostName(alias)
+	err := s.hosts.update(func(hosts map[HostName]Host) error {
+		h, found := hosts[name]
+		if !found {
+			return fmt.Errorf(fmtHostNotFound, ErrHostNotFound, name)
+		}
+		if _, found := hosts[alias]; found {
+			return fmt.Errorf("host '%s' already exists", alias)
+		}
+		pattern, err := newHostPattern(alias, h)
+		if err != nil {
+			return err
+		}
+		hosts[alias] = h
+		if pattern != nil {
+			s.updateHostPatterns(func(patterns []*hostPattern) []*hostPattern {
+				return append(patterns, pattern)
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		s.Infow("AddHostAlias", "Name", name, "Alias", alias, "Error", err)
+		return err
+	}
+	s.Infow("AddHostAlias", "Name", name, "Alias", alias, "Added", true)
+	return nil
+}
+
+// updateHostPatterns publishes a modified copy of the host patterns. It must only be called while updating the hosts
+// table, which serialises changes to both.
+func (s *server) updateHostPatterns(f func(patterns []*hostPattern) []*hostPattern) {
+	var patterns []*hostPattern
+	if current := s.hostPatterns.Load(); current != nil {
+		patterns = slices.Clone(*current)
+	}
+	patterns = f(patterns)
+	sortHostPatterns(patterns)
+	s.hostPatterns.Store(&patterns)
+}
+
+func (s *server) SetNotFoundHandler(f ResweaveFunc) {
+	s.fallback.setNotFound(f)
+}
+
+func (s *server) SetMethodNotAllowedHandler(f ResweaveFunc) {
+	s.fallback.setMethodNotAllowed(f)
 }
 
 func (s *server) AddInterceptor(f Interceptor) {
-	s.interceptor = f(s.interceptor)
+	s.interceptors.add(f, http.HandlerFunc(s.serve))
+}
+
+// chain returns the head of the Server's interceptor chain, which ends by routing the request to its Host.
+func (s *server) chain() http.Handler {
+	return s.interceptors.handler(http.HandlerFunc(s.serve))
 }
 
 func (s *server) setRequestIDInterceptor(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		reqID := uuid.New()
-		req := r.WithContext(context.WithValue(r.Context(), KeyRequestID, reqID.String()))
+		ctx := context.WithValue(r.Context(), KeyRequestID, reqID.String())
+		req := r.WithContext(context.WithValue(ctx, KeyRequestPath, r.URL.Path))
 		// Since is the entry point to the system, provide the option to debug log the request ID from here.
 		if s.Logger() != nil {
 			s.Debugw("Incoming Request", "State", "Starting", "ID", reqID.String())
@@ -1,8 +1,11 @@
 package resweave
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -31,13 +34,14 @@ var _ = Describe("Server", func() {
 			Expect(s).ToNot(BeNil())
 			Expect(s.Port()).To(Equal(port))
 			Expect(s.(*server).Logger()).To(BeNil())
-			Expect(s.(*server).interceptor).ToNot(BeNil())
+			Expect(s.(*server).chain()).ToNot(BeNil())
 		})
 		It("should be possible to create a new http.Server with the appropriate timeouts", func() {
 			srv := s.(*server).createHTTPServer()
 			Expect(srv).ToNot(BeNil())
 			Expect(srv.Addr).To(Equal(fmt.Sprintf(":%d", port)))
 			Expect(srv.ReadHeaderTimeout).To(Equal(3 * time.Second))
+			Expect(srv.Handler).To(Equal(s))
 		})
 		It("should not be possible to add a nil Resource", func() {
 			Expect(s.AddResource(nil)).To(HaveOccurred())
@@ -55,20 +59,20 @@ var _ = Describe("Server", func() {
 			Expect(err).ToNot(HaveOccurred())
 			s.SetLogger(l.Sugar(), false)
 			Expect(s.(*server).Logger()).ToNot(BeNil())
-			Expect(s.(*server).hosts[""].Logger()).To(BeNil())
+			Expect(s.(*server).hosts.load()[""].Logger()).To(BeNil())
 			s.SetLogger(nil, false)
 			Expect(s.(*server).Logger()).To(BeNil())
-			Expect(s.(*server).hosts[""].Logger()).To(BeNil())
+			Expect(s.(*server).hosts.load()[""].Logger()).To(BeNil())
 		})
 		It("should be possible to recursively set a logger on the server", func() {
 			l, err := zap.NewProduction()
 			Expect(err).ToNot(HaveOccurred())
 			s.SetLogger(l.Sugar(), true)
 			Expect(s.(*server).Logger()).ToNot(BeNil())
-			Expect(s.(*server).hosts[""].Logger()).ToNot(BeNil())
+			Expect(s.(*server).hosts.load()[""].Logger()).ToNot(BeNil())
 			s.SetLogger(nil, true)
 			Expect(s.(*server).Logger()).To(BeNil())
-			Expect(s.(*server).hosts[""].Logger()).To(BeNil())
+			Expect(s.(*server).hosts.load()[""].Logger()).To(BeNil())
 		})
 		It("should be possible to add an interceptor", func() {
 			ic1 := 0
@@ -76,7 +80,7 @@ var _ = Describe("Server", func() {
 
 			recorder := httptest.NewRecorder()
 			req := httptest.NewRequest(http.MethodGet, "/", nil)
-			s.(*server).interceptor.ServeHTTP(recorder, req)
+			s.(*server).chain().ServeHTTP(recorder, req)
 			Expect(ic1).To(BeZero())
 			Expect(ic2).To(BeZero())
 
@@ -88,7 +92,7 @@ var _ = Describe("Server", func() {
 			})
 			recorder = httptest.NewRecorder()
 			req = httptest.NewRequest(http.MethodGet, "/", nil)
-			s.(*server).interceptor.ServeHTTP(recorder, req)
+			s.(*server).chain().ServeHTTP(recorder, req)
 			Expect(ic1).To(Equal(1))
 			Expect(ic2).To(BeZero())
 
@@ -103,10 +107,8 @@ var _ = Describe("Server", func() {
 			l, err := zap.NewProduction()
 			Expect(err).ToNot(HaveOccurred())
 			s.SetLogger(l.Sugar(), true)
-			// Necessary whitebox test:  s.(*server).setRequetIDInterceptor(s.interceptor) is called when executing Run()
-			// To simulate the full request chain, it is necessary to call the following, rather than just interceptor.ServeHTTP
-			srvr := s.(*server)
-			srvr.setRequestIDInterceptor(srvr.interceptor).ServeHTTP(recorder, req)
+			// ServeHTTP runs the full request chain, including the request ID interceptor.
+			s.ServeHTTP(recorder, req)
 			Expect(ic1).To(Equal(2))
 			Expect(ic2).To(Equal(1))
 
@@ -201,5 +203,532 @@ var _ = Describe("Server", func() {
 				Expect(response2.StatusCode).To(Equal(http.StatusNotFound))
 			})
 		})
+		Describe("Host Patterns", func() {
+			// serveHost adds a resource answering with the host name and labels to h.
+			serveHost := func(h Host) {
+				api := NewAPI("")
+				api.SetList(func(ctx context.Context, w http.ResponseWriter, _ *http.Request) {
+					_, _ = fmt.Fprintf(w, "%s %v", h.Name(), GetHostLabels(ctx))
+				})
+				Expect(h.AddResource(api)).To(Succeed())
+			}
+			get := func(host string) string {
+				recorder := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodGet, "http://"+host+"/", nil)
+				s.ServeHTTP(recorder, req)
+				return recorder.Body.String()
+			}
+			BeforeEach(func() {
+				serveHost(s.(*server).getDefaultHost())
+				for _, name := range []HostName{
+					"admin.app.example.com",
+					"*.example.com",
+					"*.app.example.com",
+					`~([a-z]+)-([0-9]+)\.example\.org`,
+					`~.*\.org`,
+				} {
+					h, err := s.AddHost(name)
+					Expect(err).ToNot(HaveOccurred())
+					serveHost(h)
+				}
+			})
+			It("should prefer exact hosts over patterns", func() {
+				Expect(get("admin.app.example.com:8080")).To(Equal("admin.app.example.com []"))
+			})
+			It("should match the most specific wildcard and expose its labels", func() {
+				Expect(get("acme.app.example.com")).To(Equal("*.app.example.com [acme]"))
+				Expect(get("EU.Acme.app.example.com")).To(Equal("*.app.example.com [eu acme]"))
+				Expect(get("www.example.com")).To(Equal("*.example.com [www]"))
+			})
+			It("should require at least one label in front of a wildcard", func() {
+				Expect(get("example.com")).To(Equal(" []"))
+				Expect(get(".example.com")).To(Equal(" []"))
+			})
+			It("should match regex hosts in the order added, exposing the sub-matches", func() {
+				Expect(get("acme-42.example.org")).To(Equal(`~([a-z]+)-([0-9]+)\.example\.org [acme 42]`))
+				Expect(get("acme.example.org")).To(Equal(`~.*\.org []`))
+				Expect(get("acme-42.example.org.evil.com")).To(Equal(" []"))
+			})
+			It("should stop matching a removed pattern", func() {
+				_, err := s.RemoveHost("*.app.example.com")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(get("acme.app.example.com")).To(Equal("*.example.com [acme app]"))
+			})
+			It("should reject invalid patterns", func() {
+				for _, name := range []HostName{"*.", "*.*.example.com", "~([a-z"} {
+					h, err := s.AddHost(name)
+					Expect(err).To(MatchError(ErrInvalidHostPattern))
+					Expect(h).To(BeNil())
+				}
+			})
+		})
+	})
+	Describe("Host Aliases", func() {
+		var (
+			s Server
+			h Host
+		)
+		get := func(target string) *http.Response {
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+			return recorder.Result()
+		}
+		BeforeEach(func() {
+			s = NewServer(port)
+			var err error
+			h, err = s.AddHost("example.com")
+			Expect(err).ToNot(HaveOccurred())
+			api := NewAPI("users")
+			api.SetList(func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte("users"))
+			})
+			Expect(h.AddResource(api)).To(Succeed())
+			Expect(s.AddHostAlias("example.com", "www.example.com")).To(Succeed())
+			Expect(s.AddHostAlias("example.com", "*.example.net")).To(Succeed())
+		})
+		It("should serve the same resources under every alias", func() {
+			for _, target := range []string{"http://example.com/users", "http://www.example.com/users", "http://www.example.net/users"} {
+				resp := get(target)
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			}
+			alias, found := s.GetHost("www.example.com")
+			Expect(found).To(BeTrue())
+			Expect(alias).To(BeIdenticalTo(h))
+		})
+		It("should list an aliased host once", func() {
+			Expect(s.Routes()).To(HaveLen(1))
+			Expect(s.Routes()[0].Host).To(Equal(HostName("example.com")))
+		})
+		It("should not be possible to alias a missing host or reuse a name", func() {
+			Expect(s.AddHostAlias("missing.com", "alias.com")).To(MatchError(ErrHostNotFound))
+			Expect(s.AddHostAlias("example.com", "www.example.com")).To(HaveOccurred())
+			Expect(s.AddHostAlias("example.com", "~([a-z")).To(MatchError(ErrInvalidHostPattern))
+		})
+		It("should only remove the alias when removing by alias", func() {
+			removed, err := s.RemoveHost("www.example.com")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(removed).To(BeIdenticalTo(h))
+			Expect(get("http://www.example.com/users").StatusCode).To(Equal(http.StatusNotFound))
+			Expect(get("http://example.com/users").StatusCode).To(Equal(http.StatusOK))
+		})
+		It("should remove all aliases when removing the host", func() {
+			_, err := s.RemoveHost("example.com")
+			Expect(err).ToNot(HaveOccurred())
+			for _, target := range []string{"http://example.com/users", "http://www.example.com/users", "http://www.example.net/users"} {
+				Expect(get(target).StatusCode).To(Equal(http.StatusNotFound))
+			}
+		})
+		Describe("Canonical Redirects", func() {
+			It("should redirect aliases to the canonical name keeping the port, path and query", func() {
+				Expect(h.SetCanonicalRedirect(http.StatusMovedPermanently)).To(Succeed())
+				resp := get("http://www.example.com:8080/users?page=2")
+				Expect(resp.StatusCode).To(Equal(http.StatusMovedPermanently))
+				Expect(resp.Header.Get("Location")).To(Equal("http://example.com:8080/users?page=2"))
+
+				Expect(h.SetCanonicalRedirect(http.StatusPermanentRedirect)).To(Succeed())
+				resp = get("https://foo.example.net/users")
+				Expect(resp.StatusCode).To(Equal(http.StatusPermanentRedirect))
+				Expect(resp.Header.Get("Location")).To(Equal("https://example.com/users"))
+			})
+			It("should serve requests made with the canonical name", func() {
+				Expect(h.SetCanonicalRedirect(http.StatusPermanentRedirect)).To(Succeed())
+				Expect(get("http://example.com:8080/users").StatusCode).To(Equal(http.StatusOK))
+			})
+			It("should be possible to disable the redirect", func() {
+				Expect(h.SetCanonicalRedirect(http.StatusMovedPermanently)).To(Succeed())
+				Expect(h.SetCanonicalRedirect(0)).To(Succeed())
+				Expect(get("http://www.example.com/users").StatusCode).To(Equal(http.StatusOK))
+			})
+			It("should reject other status codes", func() {
+				Expect(h.SetCanonicalRedirect(http.StatusFound)).To(MatchError(ErrInvalidRedirectCode))
+			})
+		})
+	})
+	Describe("Runtime Changes", func() {
+		var (
+			s Server
+		)
+		BeforeEach(func() {
+			s = NewServer(port)
+		})
+		It("should be possible to remove a named host", func() {
+			h, err := s.AddHost("daniel-taylor.ca")
+			Expect(err).ToNot(HaveOccurred())
+			removed, err := s.RemoveHost("daniel-taylor.ca")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(removed).To(Equal(h))
+			_, found := s.GetHost("daniel-taylor.ca")
+			Expect(found).To(BeFalse())
+			_, err = s.RemoveHost("daniel-taylor.ca")
+			Expect(err).To(MatchError(ErrHostNotFound))
+		})
+		It("should not be possible to remove the default host", func() {
+			_, err := s.RemoveHost(defaultHostName)
+			Expect(err).To(MatchError(ErrDefaultHostRemoval))
+		})
+		It("should be possible to remove and replace resources on the default host", func() {
+			Expect(s.AddResource(NewAPI("users"))).To(Succeed())
+			replacement := NewAPI("users")
+			_, err := s.ReplaceResource(replacement)
+			Expect(err).ToNot(HaveOccurred())
+			removed, err := s.RemoveResource("users")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(removed).To(Equal(replacement))
+		})
+		It("should be possible to swap resources while requests are being served", func() {
+			newFlagged := func(status int) APIResource {
+				res := NewAPI("flagged")
+				res.SetList(func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+					w.WriteHeader(status)
+				})
+				return res
+			}
+			Expect(s.AddResource(newFlagged(http.StatusOK))).To(Succeed())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := 0; i < 200; i++ {
+					_, _ = s.ReplaceResource(newFlagged(http.StatusOK + i%2))
+				}
+			}()
+			for i := 0; i < 200; i++ {
+				recorder := httptest.NewRecorder()
+				s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/flagged", nil))
+				Expect(recorder.Code).To(BeElementOf(http.StatusOK, http.StatusCreated))
+			}
+			Eventually(done).Should(BeClosed())
+		})
+	})
+	Describe("http.Handler", func() {
+		newGreetingServer := func(greeting string) Server {
+			srv := NewServer(0)
+			res := NewAPI("greeting")
+			res.SetList(func(ctx context.Context, w http.ResponseWriter, _ *http.Request) {
+				reqID, _ := ctx.Value(KeyRequestID).(string)
+				w.Header().Set("X-Request-ID", reqID)
+				_, _ = w.Write([]byte(greeting))
+			})
+			Expect(srv.AddResource(res)).To(Succeed())
+			return srv
+		}
+		get := func(url string) (*http.Response, string) {
+			resp, err := http.Get(url)
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+			data, err := io.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			return resp, string(data)
+		}
+		It("should be possible to serve several servers in the same process", func() {
+			one := httptest.NewServer(newGreetingServer("hello"))
+			defer one.Close()
+			two := httptest.NewServer(newGreetingServer("bonjour"))
+			defer two.Close()
+
+			resp, body := get(one.URL + "/greeting")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("hello"))
+			resp, body = get(two.URL + "/greeting")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("bonjour"))
+		})
+		It("should assign a request ID and run the interceptors", func() {
+			srv := newGreetingServer("hello")
+			intercepted := 0
+			srv.AddInterceptor(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					intercepted++
+					Expect(r.Context().Value(KeyRequestID)).ToNot(BeNil())
+					next.ServeHTTP(w, r)
+				})
+			})
+			ts := httptest.NewServer(srv)
+			defer ts.Close()
+
+			resp, _ := get(ts.URL + "/greeting")
+			Expect(resp.Header.Get("X-Request-ID")).ToNot(BeEmpty())
+			Expect(intercepted).To(Equal(1))
+		})
+		It("should be possible to mount a server in another mux", func() {
+			mux := http.NewServeMux()
+			mux.Handle("/api/", http.StripPrefix("/api", newGreetingServer("hello")))
+			mux.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})
+			ts := httptest.NewServer(mux)
+			defer ts.Close()
+
+			resp, body := get(ts.URL + "/api/greeting")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("hello"))
+			resp, _ = get(ts.URL + "/health")
+			Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+		})
+	})
+	Describe("Tuning", func() {
+		It("should apply the http.Server options", func() {
+			hookCalls := 0
+			hook := func(net.Conn, http.ConnState) { hookCalls++ }
+			s := NewServer(port,
+				WithReadTimeout(time.Second),
+				WithReadHeaderTimeout(2*time.Second),
+				WithWriteTimeout(3*time.Second),
+				WithIdleTimeout(4*time.Second),
+				WithMaxHeaderBytes(1024),
+				WithConnStateHook(hook),
+				WithConnStateHook(hook),
+			)
+			srv := s.(*server).createHTTPServer()
+			Expect(srv.ReadTimeout).To(Equal(time.Second))
+			Expect(srv.ReadHeaderTimeout).To(Equal(2 * time.Second))
+			Expect(srv.WriteTimeout).To(Equal(3 * time.Second))
+			Expect(srv.IdleTimeout).To(Equal(4 * time.Second))
+			Expect(srv.MaxHeaderBytes).To(Equal(1024))
+			Expect(srv.ConnState).ToNot(BeNil())
+			srv.ConnState(nil, http.StateNew)
+			Expect(hookCalls).To(Equal(2))
+		})
+		Describe("Running", func() {
+			var (
+				s      Server
+				cancel context.CancelFunc
+			)
+			run := func(opts ...ServerOption) string {
+				s = NewServer(0, append([]ServerOption{WithAddress("127.0.0.1:0")}, opts...)...)
+				res := NewAPI("ping")
+				res.SetList(func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+					_, _ = w.Write([]byte("pong"))
+				})
+				Expect(s.AddResource(res)).To(Succeed())
+				var ctx context.Context
+				ctx, cancel = context.WithCancel(context.Background())
+				go func() {
+					_ = s.RunContext(ctx)
+				}()
+				Eventually(s.Addrs).Should(HaveLen(1))
+				return s.Addrs()[0].String()
+			}
+			AfterEach(func() {
+				cancel()
+			})
+			It("should call the connection state hooks", func() {
+				states := make(chan http.ConnState, 10)
+				addr := run(WithConnStateHook(func(_ net.Conn, state http.ConnState) {
+					states <- state
+				}))
+				resp, err := http.Get("http://" + addr + "/ping")
+				Expect(err).ToNot(HaveOccurred())
+				resp.Body.Close()
+				Eventually(states).Should(Receive(Equal(http.StateNew)))
+				Eventually(states).Should(Receive(Equal(http.StateActive)))
+			})
+			It("should close connections when keep-alives are disabled", func() {
+				addr := run(WithKeepAlives(false))
+				resp, err := http.Get("http://" + addr + "/ping")
+				Expect(err).ToNot(HaveOccurred())
+				resp.Body.Close()
+				Expect(resp.Close).To(BeTrue())
+			})
+			It("should serve HTTP/2 over cleartext when h2c is enabled", func() {
+				addr := run(WithH2C(), WithHTTP2Config(http.HTTP2Config{MaxConcurrentStreams: 10}))
+				Expect(s.(*server).createHTTPServer().HTTP2.MaxConcurrentStreams).To(Equal(10))
+
+				protocols := &http.Protocols{}
+				protocols.SetUnencryptedHTTP2(true)
+				client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+				resp, err := client.Get("http://" + addr + "/ping")
+				Expect(err).ToNot(HaveOccurred())
+				defer resp.Body.Close()
+				Expect(resp.ProtoMajor).To(Equal(2))
+				data, err := io.ReadAll(resp.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(data)).To(Equal("pong"))
+
+				resp, err = http.Get("http://" + addr + "/ping")
+				Expect(err).ToNot(HaveOccurred())
+				resp.Body.Close()
+				Expect(resp.ProtoMajor).To(Equal(1))
+			})
+			It("should not serve HTTP/2 over cleartext by default", func() {
+				addr := run()
+				protocols := &http.Protocols{}
+				protocols.SetUnencryptedHTTP2(true)
+				client := &http.Client{Transport: &http.Transport{Protocols: protocols}, Timeout: time.Second}
+				_, err := client.Get("http://" + addr + "/ping")
+				Expect(err).To(HaveOccurred())
+			})
+			It("should limit the number of concurrent connections", func() {
+				addr := run(WithMaxConnections(1))
+				held, err := net.Dial("tcp", addr)
+				Expect(err).ToNot(HaveOccurred())
+
+				client := &http.Client{Timeout: 200 * time.Millisecond}
+				Eventually(func() error {
+					resp, err := client.Get("http://" + addr + "/ping")
+					if err == nil {
+						resp.Body.Close()
+					}
+					return err
+				}).Should(HaveOccurred())
+
+				held.Close()
+				Eventually(func() error {
+					resp, err := client.Get("http://" + addr + "/ping")
+					if err == nil {
+						resp.Body.Close()
+					}
+					return err
+				}).Should(Succeed())
+			})
+		})
+	})
+	Describe("Lifecycle", func() {
+		var (
+			s       Server
+			srvPort int
+			baseURL string
+		)
+		BeforeEach(func() {
+			srvPort = freePort()
+			baseURL = fmt.Sprintf("http://127.0.0.1:%d", srvPort)
+			s = NewServer(srvPort)
+		})
+		runServer := func(ctx context.Context) <-chan error {
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- s.RunContext(ctx)
+			}()
+			Eventually(func() error {
+				conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", srvPort))
+				if err == nil {
+					conn.Close()
+				}
+				return err
+			}).Should(Succeed())
+			return errCh
+		}
+		It("should return nil and run the shutdown hooks once the context is cancelled", func() {
+			var order []string
+			res := NewAPI("users")
+			res.AddShutdownHook(func(context.Context) error {
+				order = append(order, "resource")
+				return nil
+			})
+			Expect(s.AddResource(res)).To(Succeed())
+			h, found := s.GetHost(defaultHostName)
+			Expect(found).To(BeTrue())
+			h.AddShutdownHook(func(context.Context) error {
+				order = append(order, "host")
+				return nil
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			errCh := runServer(ctx)
+			cancel()
+			Eventually(errCh).Should(Receive(BeNil()))
+			Expect(order).To(Equal([]string{"resource", "host"}))
+		})
+		It("should wait for active requests to complete before returning", func() {
+			started := make(chan struct{})
+			res := NewAPI("slow")
+			res.SetList(func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+				close(started)
+				time.Sleep(200 * time.Millisecond)
+				w.WriteHeader(http.StatusOK)
+			})
+			Expect(s.AddResource(res)).To(Succeed())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			errCh := runServer(ctx)
+			respCh := make(chan int, 1)
+			go func() {
+				defer GinkgoRecover()
+				resp, err := http.Get(baseURL + "/slow")
+				Expect(err).ToNot(HaveOccurred())
+				resp.Body.Close()
+				respCh <- resp.StatusCode
+			}()
+			Eventually(started).Should(BeClosed())
+			cancel()
+			Eventually(respCh).Should(Receive(Equal(http.StatusOK)))
+			Eventually(errCh).Should(Receive(BeNil()))
+		})
+		It("should return ErrShutdownDeadlineExceeded if active requests outlive the deadline", func() {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			defer close(release)
+			res := NewAPI("stuck")
+			res.SetList(func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+				close(started)
+				<-release
+				w.WriteHeader(http.StatusOK)
+			})
+			hookRan := false
+			res.AddShutdownHook(func(context.Context) error {
+				hookRan = true
+				return nil
+			})
+			Expect(s.AddResource(res)).To(Succeed())
+
+			errCh := runServer(context.Background())
+			go func() {
+				if resp, err := http.Get(baseURL + "/stuck"); err == nil {
+					resp.Body.Close()
+				}
+			}()
+			Eventually(started).Should(BeClosed())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			err := s.Shutdown(ctx)
+			Expect(err).To(MatchError(ErrShutdownDeadlineExceeded))
+			Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+			Expect(hookRan).To(BeTrue())
+			Eventually(errCh).Should(Receive(BeNil()))
+		})
+		It("should use the shutdown timeout when the run context is cancelled", func() {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			defer close(release)
+			res := NewAPI("stuck")
+			res.SetList(func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+				close(started)
+				<-release
+			})
+			Expect(s.AddResource(res)).To(Succeed())
+			s.SetShutdownTimeout(50 * time.Millisecond)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			errCh := runServer(ctx)
+			go func() {
+				if resp, err := http.Get(baseURL + "/stuck"); err == nil {
+					resp.Body.Close()
+				}
+			}()
+			Eventually(started).Should(BeClosed())
+			cancel()
+			Eventually(errCh).Should(Receive(MatchError(ErrShutdownDeadlineExceeded)))
+		})
+		It("should return the errors of failing shutdown hooks", func() {
+			hookErr := errors.New("hook failed")
+			res := NewAPI("users")
+			res.AddShutdownHook(func(context.Context) error { return hookErr })
+			Expect(s.AddResource(res)).To(Succeed())
+			Expect(s.Shutdown(context.Background())).To(MatchError(hookErr))
+		})
+		It("should return the listen error if the server cannot start", func() {
+			l, err := net.Listen("tcp", fmt.Sprintf(":%d", srvPort))
+			Expect(err).ToNot(HaveOccurred())
+			defer l.Close()
+			Expect(s.RunContext(context.Background())).To(HaveOccurred())
+		})
 	})
 })
+
+// freePort finds a currently unused TCP port for tests which need to run a real server.
+func freePort() int {
+	l, err := net.Listen("tcp", ":0")
+	Expect(err).ToNot(HaveOccurred())
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
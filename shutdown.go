@@ -0,0 +1,62 @@
+package resweave
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ShutdownHook is a function run by the Server once it has stopped accepting connections and
+// in-flight requests have drained (or the shutdown deadline has passed).
+// The provided context carries the shutdown deadline.
+type ShutdownHook func(ctx context.Context) error
+
+// Shutdowner is implemented by Hosts and Resources which need to release state when the Server shuts down.
+type Shutdowner interface {
+	// Shutdown runs any registered shutdown hooks, returning the joined errors of all hooks which failed.
+	Shutdown(ctx context.Context) error
+}
+
+// shutdownHooks holds the shutdown hooks registered against a Host or Resource.
+type shutdownHooks struct {
+	mtx   sync.Mutex
+	hooks []ShutdownHook
+}
+
+func (sh *shutdownHooks) add(hook ShutdownHook) {
+	if hook == nil {
+		return
+	}
+	sh.mtx.Lock()
+	defer sh.mtx.Unlock()
+	sh.hooks = append(sh.hooks, hook)
+}
+
+// run executes the hooks in the order they were added; every hook is run even if an earlier one fails.
+func (sh *shutdownHooks) run(ctx context.Context) error {
+	sh.mtx.Lock()
+	hooks := make([]ShutdownHook, len(sh.hooks))
+	copy(hooks, sh.hooks)
+	sh.mtx.Unlock()
+
+	var errs []error
+	for _, hook := range hooks {
+		if err := hook(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// shutdownResources calls Shutdown on every resource in the map which implements Shutdowner.
+func shutdownResources(ctx context.Context, resources ResourceMap) error {
+	var errs []error
+	for _, r := range resources {
+		if sd, ok := r.(Shutdowner); ok {
+			if err := sd.Shutdown(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
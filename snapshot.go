@@ -0,0 +1,48 @@
+package resweave
+
+import (
+	"maps"
+	"sync"
+	"sync/atomic"
+)
+
+// routeTable is a copy-on-write map used for all routing state.
+// Readers load an immutable snapshot without locking, so requests never observe a partially applied change, while
+// writers are serialised, copy the current snapshot, modify the copy and atomically publish it.
+// The zero value is an empty table ready for use.
+type routeTable[K comparable, V any] struct {
+	mtx  sync.Mutex
+	snap atomic.Pointer[map[K]V]
+}
+
+// load returns the current snapshot. It MUST NOT be modified.
+func (t *routeTable[K, V]) load() map[K]V {
+	if m := t.snap.Load(); m != nil {
+		return *m
+	}
+	return nil
+}
+
+func (t *routeTable[K, V]) get(key K) (V, bool) {
+	v, found := t.load()[key]
+	return v, found
+}
+
+func (t *routeTable[K, V]) len() int {
+	return len(t.load())
+}
+
+// update applies f to a copy of the current snapshot, publishing the copy only if f succeeds.
+func (t *routeTable[K, V]) update(f func(m map[K]V) error) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	next := maps.Clone(t.load())
+	if next == nil {
+		next = make(map[K]V)
+	}
+	if err := f(next); err != nil {
+		return err
+	}
+	t.snap.Store(&next)
+	return nil
+}
@@ -0,0 +1,164 @@
+package resweave
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Route Snapshots", func() {
+	Describe("routeTable", func() {
+		It("should be usable as a zero value", func() {
+			var t routeTable[string, int]
+			Expect(t.len()).To(BeZero())
+			_, found := t.get("one")
+			Expect(found).To(BeFalse())
+			Expect(t.update(func(m map[string]int) error {
+				m["one"] = 1
+				return nil
+			})).To(Succeed())
+			v, found := t.get("one")
+			Expect(found).To(BeTrue())
+			Expect(v).To(Equal(1))
+		})
+		It("should not publish a failed update", func() {
+			var t routeTable[string, int]
+			errFail := errors.New("fail")
+			Expect(t.update(func(m map[string]int) error {
+				m["one"] = 1
+				return errFail
+			})).To(MatchError(errFail))
+			Expect(t.len()).To(BeZero())
+		})
+		It("should leave previously loaded snapshots unchanged", func() {
+			var t routeTable[string, int]
+			Expect(t.update(func(m map[string]int) error {
+				m["one"] = 1
+				return nil
+			})).To(Succeed())
+			snap := t.load()
+			Expect(t.update(func(m map[string]int) error {
+				delete(m, "one")
+				m["two"] = 2
+				return nil
+			})).To(Succeed())
+			Expect(snap).To(Equal(map[string]int{"one": 1}))
+			Expect(t.load()).To(Equal(map[string]int{"two": 2}))
+		})
+	})
+
+	// These specs only prove anything when run with -race; without it they simply check nothing panics.
+	Describe("Mutating while serving", func() {
+		const (
+			workers    = 8
+			iterations = 200
+		)
+		var (
+			s   Server
+			ts  *httptest.Server
+			api APIResource
+		)
+		ok := func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}
+		BeforeEach(func() {
+			s = NewServer(0)
+			api = NewAPI("users")
+			api.SetList(ok)
+			api.SetFetch(ok)
+			Expect(s.AddResource(api)).To(Succeed())
+			Expect(s.AddResource(NewHTML("static", GinkgoT().TempDir()))).To(Succeed())
+			ts = httptest.NewServer(s)
+		})
+		AfterEach(func() {
+			ts.Close()
+		})
+		It("should serve consistently while every routing table changes", func() {
+			paths := []string{"/users", "/users/1", "/users/1/profile", "/users/sub", "/static/", "/missing"}
+			hosts := []string{"", "a.example.com", "b.example.com"}
+
+			var wg sync.WaitGroup
+			for i := range workers {
+				wg.Go(func() {
+					defer GinkgoRecover()
+					for j := range iterations {
+						req, err := http.NewRequest(http.MethodGet, ts.URL+paths[(i+j)%len(paths)], nil)
+						Expect(err).ToNot(HaveOccurred())
+						if host := hosts[j%len(hosts)]; host != "" {
+							req.Host = host
+						}
+						resp, err := ts.Client().Do(req)
+						Expect(err).ToNot(HaveOccurred())
+						_, _ = io.Copy(io.Discard, resp.Body)
+						_ = resp.Body.Close()
+						Expect(resp.StatusCode).To(BeNumerically("<", http.StatusInternalServerError))
+					}
+				})
+			}
+
+			wg.Go(func() {
+				defer GinkgoRecover()
+				for j := range iterations {
+					name := HostName(hosts[1+j%2])
+					if h, err := s.AddHost(name); err == nil {
+						_ = h.AddResource(NewAPI("users"))
+					} else {
+						_, _ = s.RemoveHost(name)
+					}
+				}
+			})
+			wg.Go(func() {
+				defer GinkgoRecover()
+				for j := range iterations {
+					sub := NewAPI("sub")
+					sub.SetList(ok)
+					profile := NewAPI("profile")
+					profile.SetFetch(ok)
+					if j%2 == 0 {
+						_ = api.AddResource(sub)
+						_ = api.AddChildResource(profile)
+					} else {
+						_, _ = api.RemoveResource("sub")
+						_, _ = api.RemoveChildResource("profile")
+					}
+					replacement := NewAPI("users")
+					replacement.SetList(ok)
+					_, _ = s.ReplaceResource(replacement)
+					_, _ = s.ReplaceResource(api)
+				}
+			})
+			wg.Go(func() {
+				defer GinkgoRecover()
+				for j := range iterations {
+					if j%2 == 0 {
+						api.SetList(nil)
+						Expect(api.SetID(ID(`([a-z0-9]+)`))).To(Succeed())
+					} else {
+						api.SetList(ok)
+						Expect(api.SetID(NumericID)).To(Succeed())
+					}
+					api.SetHandler(nil)
+				}
+			})
+			wg.Go(func() {
+				defer GinkgoRecover()
+				for j := range iterations / 10 {
+					s.AddInterceptor(func(next http.Handler) http.Handler {
+						return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+							w.Header().Add("X-Interceptor", fmt.Sprint(j))
+							next.ServeHTTP(w, r)
+						})
+					})
+				}
+			})
+			wg.Wait()
+		})
+	})
+})
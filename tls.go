@@ -0,0 +1,203 @@
+package resweave
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// WithTLSFiles serves TLS using the PEM encoded certificate and key files provided.
+// The files are checked for changes on each TLS handshake and reloaded when they are modified on disk,
+// allowing certificates to be rotated without restarting the Server.
+func WithTLSFiles(certFile, keyFile string) ServerOption {
+	return func(s *server) {
+		s.certFile = certFile
+		s.keyFile = keyFile
+	}
+}
+
+// WithTLSConfig serves TLS using the provided tls.Config.
+// If WithTLSFiles is also used, the certificate files take precedence over any certificate in the configuration.
+func WithTLSConfig(cfg *tls.Config) ServerOption {
+	return func(s *server) {
+		s.tlsConfig = cfg
+	}
+}
+
+// WithHTTPSRedirect runs a second, plain HTTP, listener on httpPort which redirects every request to the HTTPS server.
+// GET and HEAD requests are redirected with a 301 (Moved Permanently), all others with a 308 (Permanent Redirect)
+// so that the method and body are preserved.
+func WithHTTPSRedirect(httpPort int) ServerOption {
+	return func(s *server) {
+		s.redirectPort = httpPort
+	}
+}
+
+// WithHSTS adds a Strict-Transport-Security header with the provided max age to every response served over TLS.
+func WithHSTS(maxAge time.Duration, includeSubDomains bool) ServerOption {
+	return func(s *server) {
+		s.hsts = fmt.Sprintf("max-age=%d", int64(maxAge.Seconds()))
+		if includeSubDomains {
+			s.hsts += "; includeSubDomains"
+		}
+	}
+}
+
+func (s *server) tlsEnabled() bool {
+	if s.tlsConfig != nil || (s.certFile != "" && s.keyFile != "") {
+		return true
+	}
+	for _, h := range s.hostList() {
+		if h.HasCertificate() {
+			return true
+		}
+	}
+	return false
+}
+
+// buildTLSConfig creates the tls.Config for the Server.
+// Certificates are selected via SNI from the Hosts first (see getCertificate), falling back to the certificate
+// files or tls.Config provided to the Server.
+func (s *server) buildTLSConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if s.tlsConfig != nil {
+		cfg = s.tlsConfig.Clone()
+	}
+	fallback := cfg.GetCertificate
+	if s.certFile != "" && s.keyFile != "" {
+		reloader, err := newCertReloader(s.certFile, s.keyFile, s)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = nil
+		fallback = reloader.GetCertificate
+	}
+	cfg.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return s.getCertificate(hello, fallback)
+	}
+	return cfg, nil
+}
+
+// getCertificate selects the certificate of the Host named in the SNI server name, then the default Host's certificate
+// and finally the fallback. A nil certificate and error allow crypto/tls to use tls.Config.Certificates instead.
+func (s *server) getCertificate(hello *tls.ClientHelloInfo, fallback func(*tls.ClientHelloInfo) (*tls.Certificate, error)) (*tls.Certificate, error) {
+	candidates := []Host{s.getDefaultHost()}
+	if h, _, found := s.matchHost(HostName(hello.ServerName).Normalize()); found {
+		candidates = append([]Host{h}, candidates...)
+	}
+	for _, h := range candidates {
+		if cert, err := h.GetCertificate(hello); err != nil || cert != nil {
+			s.Debugw("getCertificate", "Server Name", hello.ServerName, "Host", h.Name(), "Error", err)
+			return cert, err
+		}
+	}
+	if fallback != nil {
+		return fallback(hello)
+	}
+	return nil, nil
+}
+
+func (s *server) createRedirectServer() *http.Server {
+	addr := fmt.Sprintf(":%d", s.redirectPort)
+	s.Infow("createRedirectServer", "Address", addr)
+	return &http.Server{
+		Addr:              addr,
+		Handler:           http.HandlerFunc(s.redirectToHTTPS),
+		ReadHeaderTimeout: 3 * time.Second,
+	}
+}
+
+func (s *server) redirectToHTTPS(w http.ResponseWriter, req *http.Request) {
+	port := ""
+	if p := s.Port(); p != 443 {
+		port = fmt.Sprint(p)
+	}
+	host := joinHostPort(string(HostName(req.Host).StripPort()), port)
+	target := "https://" + host + req.URL.RequestURI()
+	code := http.StatusPermanentRedirect
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		code = http.StatusMovedPermanently
+	}
+	s.Infow("redirectToHTTPS", "Target", target, "Code", code)
+	http.Redirect(w, req, target, code)
+}
+
+// certReloader serves a certificate loaded from disk, reloading it whenever the certificate or key file changes.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	log      LogHolder
+
+	mtx     sync.RWMutex
+	cert    *tls.Certificate
+	certMod fileVersion
+	keyMod  fileVersion
+}
+
+// fileVersion identifies a version of a file on disk by its modification time and size.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func statVersion(name string) (fileVersion, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	return fileVersion{modTime: fi.ModTime(), size: fi.Size()}, nil
+}
+
+func newCertReloader(certFile, keyFile string, log LogHolder) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, log: log}
+	if _, err := r.reloadIfChanged(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reloadIfChanged reloads the key pair when either file differs from the loaded version, reporting whether it did so.
+func (r *certReloader) reloadIfChanged() (bool, error) {
+	certMod, err := statVersion(r.certFile)
+	if err != nil {
+		return false, err
+	}
+	keyMod, err := statVersion(r.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	r.mtx.RLock()
+	unchanged := r.cert != nil && certMod == r.certMod && keyMod == r.keyMod
+	r.mtx.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.cert = &cert
+	r.certMod = certMod
+	r.keyMod = keyMod
+	return true, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+// A failed reload (e.g. a certificate written before its key) is logged and the previous certificate is kept.
+func (r *certReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if reloaded, err := r.reloadIfChanged(); err != nil {
+		r.log.Errorw("GetCertificate", "Certificate File", r.certFile, "Reload Error", err)
+	} else if reloaded {
+		r.log.Infow("GetCertificate", "Certificate File", r.certFile, "Reloaded", true)
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	return r.cert, nil
+}
@@ -0,0 +1,284 @@
+package resweave
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TLS", func() {
+	var (
+		dir      string
+		certFile string
+		keyFile  string
+		caPool   *x509.CertPool
+		srvPort  int
+	)
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		certFile = filepath.Join(dir, "cert.pem")
+		keyFile = filepath.Join(dir, "key.pem")
+		caPool = x509.NewCertPool()
+		caPool.AddCert(writeSelfSignedCert(certFile, keyFile, 1, "localhost"))
+		srvPort = freePort()
+	})
+
+	newClient := func() *http.Client {
+		return &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig:   &tls.Config{RootCAs: caPool, MinVersion: tls.VersionTLS12},
+				DisableKeepAlives: true,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+	}
+	newServer := func(opts ...ServerOption) Server {
+		s := NewServer(srvPort, opts...)
+		res := NewAPI("secure")
+		res.SetList(func(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		Expect(s.AddResource(res)).To(Succeed())
+		return s
+	}
+	runServer := func(s Server, ports ...int) context.CancelFunc {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			_ = s.RunContext(ctx)
+		}()
+		for _, p := range append([]int{srvPort}, ports...) {
+			Eventually(func() error {
+				conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", p))
+				if err == nil {
+					conn.Close()
+				}
+				return err
+			}).Should(Succeed())
+		}
+		return cancel
+	}
+	url := func(path string) string {
+		return fmt.Sprintf("https://localhost:%d%s", srvPort, path)
+	}
+
+	It("should serve HTTPS from certificate and key files", func() {
+		cancel := runServer(newServer(WithTLSFiles(certFile, keyFile)))
+		defer cancel()
+
+		resp, err := newClient().Get(url("/secure"))
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.TLS).ToNot(BeNil())
+		Expect(resp.Header.Get("Strict-Transport-Security")).To(BeEmpty())
+	})
+	It("should serve HTTPS from a tls.Config", func() {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		Expect(err).ToNot(HaveOccurred())
+		cancel := runServer(newServer(WithTLSConfig(&tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		})))
+		defer cancel()
+
+		resp, err := newClient().Get(url("/secure"))
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	})
+	It("should reload the certificate when the files change on disk", func() {
+		cancel := runServer(newServer(WithTLSFiles(certFile, keyFile)))
+		defer cancel()
+
+		resp, err := newClient().Get(url("/secure"))
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.TLS.PeerCertificates[0].SerialNumber.Int64()).To(Equal(int64(1)))
+
+		caPool.AddCert(writeSelfSignedCert(certFile, keyFile, 2, "localhost"))
+		later := time.Now().Add(time.Minute)
+		Expect(os.Chtimes(certFile, later, later)).To(Succeed())
+		Expect(os.Chtimes(keyFile, later, later)).To(Succeed())
+
+		resp, err = newClient().Get(url("/secure"))
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.TLS.PeerCertificates[0].SerialNumber.Int64()).To(Equal(int64(2)))
+	})
+	It("should fail to run if the certificate files cannot be loaded", func() {
+		s := newServer(WithTLSFiles(filepath.Join(dir, "missing.pem"), keyFile))
+		Expect(s.RunContext(context.Background())).To(MatchError(os.ErrNotExist))
+	})
+	It("should redirect plain HTTP requests to HTTPS", func() {
+		redirectPort := freePort()
+		cancel := runServer(newServer(WithTLSFiles(certFile, keyFile), WithHTTPSRedirect(redirectPort)), redirectPort)
+		defer cancel()
+
+		plainURL := fmt.Sprintf("http://localhost:%d/secure?page=2", redirectPort)
+		resp, err := newClient().Get(plainURL)
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusMovedPermanently))
+		Expect(resp.Header.Get("Location")).To(Equal(url("/secure?page=2")))
+
+		resp, err = newClient().Post(plainURL, "text/plain", nil)
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusPermanentRedirect))
+		Expect(resp.Header.Get("Location")).To(Equal(url("/secure?page=2")))
+	})
+	It("should only emit HSTS on responses served over TLS", func() {
+		s := newServer(WithHSTS(365*24*time.Hour, true))
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/secure", nil))
+		Expect(recorder.Header().Get("Strict-Transport-Security")).To(BeEmpty())
+
+		recorder = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/secure", nil)
+		req.TLS = &tls.ConnectionState{}
+		s.ServeHTTP(recorder, req)
+		Expect(recorder.Header().Get("Strict-Transport-Security")).To(Equal("max-age=31536000; includeSubDomains"))
+	})
+	Describe("Per-host certificates", func() {
+		var (
+			s Server
+		)
+		writeHostCert := func(name string, serial int64) (string, string) {
+			hostCert := filepath.Join(dir, name+".pem")
+			hostKey := filepath.Join(dir, name+"-key.pem")
+			caPool.AddCert(writeSelfSignedCert(hostCert, hostKey, serial, name))
+			return hostCert, hostKey
+		}
+		// dialServerName performs a TLS handshake with the provided SNI server name, returning the presented certificate.
+		dialServerName := func(serverName string) (*x509.Certificate, error) {
+			conn, err := tls.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", srvPort), &tls.Config{
+				ServerName: serverName,
+				RootCAs:    caPool,
+				MinVersion: tls.VersionTLS12,
+			})
+			if err != nil {
+				return nil, err
+			}
+			defer conn.Close()
+			return conn.ConnectionState().PeerCertificates[0], nil
+		}
+		BeforeEach(func() {
+			s = newServer()
+			for i, name := range []string{"one.test", "two.test"} {
+				h, err := s.AddHost(HostName(name))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(h.SetCertificateFiles(writeHostCert(name, int64(10+i)))).To(Succeed())
+			}
+		})
+		It("should enable TLS when only hosts carry certificates", func() {
+			Expect(s.(*server).tlsEnabled()).To(BeTrue())
+		})
+		It("should select each host's certificate via SNI", func() {
+			cancel := runServer(s)
+			defer cancel()
+
+			for _, name := range []string{"one.test", "two.test"} {
+				cert, err := dialServerName(name)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cert.DNSNames).To(ConsistOf(name))
+			}
+		})
+		It("should select the certificate of a wildcard host via SNI", func() {
+			h, err := s.AddHost("*.wild.test")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(h.SetCertificateFiles(writeHostCert("*.wild.test", 30))).To(Succeed())
+			cancel := runServer(s)
+			defer cancel()
+
+			cert, err := dialServerName("acme.wild.test")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cert.DNSNames).To(ConsistOf("*.wild.test"))
+		})
+		It("should fall back to the default host's certificate", func() {
+			defaultHost, found := s.GetHost(defaultHostName)
+			Expect(found).To(BeTrue())
+			Expect(defaultHost.SetCertificateFiles(writeHostCert("default.test", 20))).To(Succeed())
+			cancel := runServer(s)
+			defer cancel()
+
+			cert, err := dialServerName("default.test")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cert.DNSNames).To(ConsistOf("default.test"))
+		})
+		It("should fall back to the server certificate when the default host has none", func() {
+			s = NewServer(srvPort, WithTLSFiles(certFile, keyFile))
+			h, err := s.AddHost("one.test")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(h.SetCertificateFiles(writeHostCert("one.test", 10))).To(Succeed())
+			cancel := runServer(s)
+			defer cancel()
+
+			cert, err := dialServerName("localhost")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cert.DNSNames).To(ConsistOf("localhost"))
+			cert, err = dialServerName("one.test")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cert.DNSNames).To(ConsistOf("one.test"))
+		})
+		It("should be possible to set and clear a static certificate", func() {
+			h := newHost("static.test")
+			Expect(h.HasCertificate()).To(BeFalse())
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			Expect(err).ToNot(HaveOccurred())
+			h.SetCertificate(&cert)
+			Expect(h.HasCertificate()).To(BeTrue())
+			Expect(h.GetCertificate(nil)).To(Equal(&cert))
+			h.SetCertificate(nil)
+			Expect(h.HasCertificate()).To(BeFalse())
+			Expect(h.GetCertificate(nil)).To(BeNil())
+		})
+		It("should return an error if the host certificate files cannot be loaded", func() {
+			h := newHost("missing.test")
+			Expect(h.SetCertificateFiles(filepath.Join(dir, "missing.pem"), keyFile)).To(MatchError(os.ErrNotExist))
+			Expect(h.HasCertificate()).To(BeFalse())
+		})
+	})
+})
+
+// writeSelfSignedCert writes a self-signed certificate and key for the provided DNS names, returning the certificate.
+func writeSelfSignedCert(certFile, keyFile string, serial int64, dnsNames ...string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:              dnsNames,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)).To(Succeed())
+	Expect(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)).To(Succeed())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).ToNot(HaveOccurred())
+	return cert
+}
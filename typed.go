@@ -0,0 +1,325 @@
+package resweave
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	mediaTypeJSON = "application/json"
+	// typedStringID is the default ID regex for TypedAPIRes with string IDs: a single path segment.
+	typedStringID ID = `^[^/]+$`
+	// typedIntegerID is the default ID regex for TypedAPIRes with integer IDs: a path segment of digits only.
+	typedIntegerID ID = `^[0-9]+$`
+)
+
+// TypedID is the set of ID types supported by TypedAPIRes.
+type TypedID interface {
+	~string | IntegerID
+}
+
+// IntegerID is the set of integer ID types supported by TypedAPIRes.
+type IntegerID interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+// TypedHandlers are the handlers of a TypedAPIRes. Handlers left nil are not served, as for an unset ResweaveFunc.
+//
+// Errors returned are written with WriteError: with the status of a StatusCoder in the error chain, 404 for
+// ErrNotFound, 409 for ErrConflict, 400 for ErrInvalid, any status mapped with WithErrorMapping and 500 otherwise.
+type TypedHandlers[T any, K TypedID] struct {
+	// List returns the items in the collection; answered with a 200.
+	List func(ctx context.Context) ([]T, error)
+	// Fetch returns the item with the ID; answered with a 200.
+	Fetch func(ctx context.Context, id K) (T, error)
+	// Create creates an item from the request body, returning the created item; answered with a 201.
+	Create func(ctx context.Context, v T) (T, error)
+	// Update replaces the item with the ID with the request body, returning the updated item; answered with a 200.
+	// PATCH requests are applied to the item returned by Fetch as a JSON merge patch (RFC 7396) and the result passed
+	// to Update; without Fetch they are answered with a 405. Either is replaced by setting a Patch handler.
+	Update func(ctx context.Context, id K, v T) (T, error)
+	// Delete deletes the item with the ID; answered with a 204.
+	Delete func(ctx context.Context, id K) error
+}
+
+// TypedAPIRes is an APIResource serving items of type T identified by IDs of type K as JSON, decoding request bodies,
+// parsing IDs and encoding responses for its TypedHandlers.
+type TypedAPIRes[T any, K TypedID] struct {
+	*BaseAPIRes
+	handlers TypedHandlers[T, K]
+}
+
+// NewTypedAPI creates a new TypedAPIRes with the provided name and handlers.
+// Integer IDs match a path segment of digits and string IDs any single path segment; either may be changed with SetID.
+func NewTypedAPI[T any, K TypedID](name ResourceName, handlers TypedHandlers[T, K]) *TypedAPIRes[T, K] {
+	tar := &TypedAPIRes[T, K]{
+		BaseAPIRes: newBaseAPIRes(name),
+		handlers:   handlers,
+	}
+	if reflect.TypeFor[K]().Kind() == reflect.String {
+		_ = tar.SetID(typedStringID)
+	} else {
+		_ = tar.SetID(typedIntegerID)
+	}
+	if handlers.List != nil {
+		tar.SetList(tar.list)
+	}
+	if handlers.Fetch != nil {
+		tar.SetFetch(tar.fetch)
+	}
+	if handlers.Create != nil {
+		tar.SetCreate(tar.create)
+	}
+	if handlers.Update != nil {
+		tar.SetUpdate(tar.update)
+	}
+	if handlers.Update != nil && handlers.Fetch != nil {
+		tar.SetPatch(tar.patch)
+	}
+	// Without Fetch a PATCH cannot preserve the rest of the item, so it must not fall back to Update.
+	tar.disablePatchFallback()
+	if handlers.Delete != nil {
+		tar.SetDelete(tar.delete)
+	}
+	return tar
+}
+
+// GetTypedID retrieves the ID for this call from the provided context, parsed as K.
+// If the ID cannot be parsed, an error wrapping ErrInvalid is returned.
+func (tar *TypedAPIRes[T, K]) GetTypedID(ctx context.Context) (K, error) {
+	var k K
+	s, err := tar.GetIDValue(ctx)
+	if err != nil {
+		return k, err
+	}
+	v := reflect.ValueOf(&k).Elem()
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return k, fmt.Errorf("%w: id '%s': %w", ErrInvalid, s, err)
+		}
+		v.SetInt(i)
+	default:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return k, fmt.Errorf("%w: id '%s': %w", ErrInvalid, s, err)
+		}
+		v.SetUint(u)
+	}
+	return k, nil
+}
+
+func (tar *TypedAPIRes[T, K]) itemType() reflect.Type {
+	return reflect.TypeFor[T]()
+}
+
+func (tar *TypedAPIRes[T, K]) idType() reflect.Type {
+	return reflect.TypeFor[K]()
+}
+
+func (tar *TypedAPIRes[T, K]) list(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	items, err := tar.handlers.List(ctx)
+	if err != nil {
+		tar.writeError(ctx, w, req, err)
+		return
+	}
+	if items == nil {
+		items = []T{}
+	}
+	tar.writeJSON(w, http.StatusOK, items)
+}
+
+func (tar *TypedAPIRes[T, K]) fetch(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	id, err := tar.GetTypedID(ctx)
+	if err != nil {
+		tar.writeError(ctx, w, req, err)
+		return
+	}
+	item, err := tar.handlers.Fetch(ctx, id)
+	if err != nil {
+		tar.writeError(ctx, w, req, err)
+		return
+	}
+	tar.writeJSON(w, http.StatusOK, item)
+}
+
+func (tar *TypedAPIRes[T, K]) create(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	v, err := tar.decode(req)
+	if err != nil {
+		tar.writeError(ctx, w, req, err)
+		return
+	}
+	item, err := tar.handlers.Create(ctx, v)
+	if err != nil {
+		tar.writeError(ctx, w, req, err)
+		return
+	}
+	tar.writeJSON(w, http.StatusCreated, item)
+}
+
+func (tar *TypedAPIRes[T, K]) update(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	if !tar.hasID(ctx) {
+		tar.defaultFunction(ctx, w, req)
+		return
+	}
+	id, err := tar.GetTypedID(ctx)
+	if err != nil {
+		tar.writeError(ctx, w, req, err)
+		return
+	}
+	v, err := tar.decode(req)
+	if err != nil {
+		tar.writeError(ctx, w, req, err)
+		return
+	}
+	item, err := tar.handlers.Update(ctx, id, v)
+	if err != nil {
+		tar.writeError(ctx, w, req, err)
+		return
+	}
+	tar.writeJSON(w, http.StatusOK, item)
+}
+
+// patch applies the JSON merge patch in the request body to the fetched item and passes the result to Update.
+func (tar *TypedAPIRes[T, K]) patch(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	if !tar.hasID(ctx) {
+		tar.defaultFunction(ctx, w, req)
+		return
+	}
+	id, err := tar.GetTypedID(ctx)
+	if err != nil {
+		tar.writeError(ctx, w, req, err)
+		return
+	}
+	var p any
+	if err := decodeJSON(req, &p); err != nil {
+		tar.writeError(ctx, w, req, err)
+		return
+	}
+	item, err := tar.handlers.Fetch(ctx, id)
+	if err != nil {
+		tar.writeError(ctx, w, req, err)
+		return
+	}
+	if item, err = mergePatch(item, p); err != nil {
+		tar.writeError(ctx, w, req, err)
+		return
+	}
+	if item, err = tar.handlers.Update(ctx, id, item); err != nil {
+		tar.writeError(ctx, w, req, err)
+		return
+	}
+	tar.writeJSON(w, http.StatusOK, item)
+}
+
+func (tar *TypedAPIRes[T, K]) delete(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	if !tar.hasID(ctx) {
+		tar.defaultFunction(ctx, w, req)
+		return
+	}
+	id, err := tar.GetTypedID(ctx)
+	if err != nil {
+		tar.writeError(ctx, w, req, err)
+		return
+	}
+	if err := tar.handlers.Delete(ctx, id); err != nil {
+		tar.writeError(ctx, w, req, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// errUnsupportedMediaType is returned by decode for request bodies which are not JSON.
+type errUnsupportedMediaType string
+
+func (e errUnsupportedMediaType) Error() string {
+	return fmt.Sprintf("unsupported media type '%s'", string(e))
+}
+
+func (e errUnsupportedMediaType) StatusCode() int {
+	return http.StatusUnsupportedMediaType
+}
+
+// decode decodes the JSON request body. A missing Content-Type is treated as JSON.
+func (tar *TypedAPIRes[T, K]) decode(req *http.Request) (T, error) {
+	var v T
+	err := decodeJSON(req, &v)
+	return v, err
+}
+
+// decodeJSON decodes the JSON request body into v. A missing Content-Type is treated as JSON.
+func decodeJSON(req *http.Request, v any) error {
+	if ct := req.Header.Get(headerContentType); ct != "" {
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mt != mediaTypeJSON && !strings.HasSuffix(mt, "+json")) {
+			return errUnsupportedMediaType(ct)
+		}
+	}
+	if err := json.NewDecoder(req.Body).Decode(v); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	return nil
+}
+
+// mergePatch applies the JSON merge patch p to the JSON encoding of item, decoding the result as a new T.
+func mergePatch[T any](item T, p any) (T, error) {
+	var patched T
+	data, err := json.Marshal(item)
+	if err != nil {
+		return patched, err
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return patched, err
+	}
+	if data, err = json.Marshal(mergeValue(doc, p)); err != nil {
+		return patched, err
+	}
+	if err := json.Unmarshal(data, &patched); err != nil {
+		return patched, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	return patched, nil
+}
+
+// mergeValue merges the patch into the target as defined by RFC 7396: objects are merged member by member, null
+// removes a member and any other value replaces the target.
+func mergeValue(target any, patch any) any {
+	members, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	merged, ok := target.(map[string]any)
+	if !ok {
+		merged = make(map[string]any, len(members))
+	}
+	for name, value := range members {
+		if value == nil {
+			delete(merged, name)
+		} else {
+			merged[name] = mergeValue(merged[name], value)
+		}
+	}
+	return merged
+}
+
+func (tar *TypedAPIRes[T, K]) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set(headerContentType, mediaTypeJSON)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		tar.Errorw("writeJSON", "Status", status, "Error", err)
+	}
+}
+
+func (tar *TypedAPIRes[T, K]) writeError(ctx context.Context, w http.ResponseWriter, req *http.Request, err error) {
+	tar.Infow("writeError", "Method", req.Method, "Status", errorStatus(ctx, err), "Error", err)
+	WriteError(ctx, w, err)
+}
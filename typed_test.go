@@ -0,0 +1,200 @@
+package resweave_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/mortedecai/resweave"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type widget struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type teapotError struct{}
+
+func (teapotError) Error() string   { return "teapot" }
+func (teapotError) StatusCode() int { return http.StatusTeapot }
+
+var _ = Describe("Typed API Resources", func() {
+	var (
+		s       resweave.Server
+		widgets map[int]widget
+		res     *resweave.TypedAPIRes[widget, int]
+	)
+	do := func(method string, target string, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		var req *http.Request
+		if body == "" {
+			req = httptest.NewRequest(method, target, nil)
+		} else {
+			req = httptest.NewRequest(method, target, strings.NewReader(body))
+		}
+		s.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		s = resweave.NewServer(0)
+		widgets = map[int]widget{1: {ID: 1, Name: "one"}}
+		res = resweave.NewTypedAPI("widgets", resweave.TypedHandlers[widget, int]{
+			List: func(_ context.Context) ([]widget, error) {
+				var all []widget
+				for i := 1; i <= len(widgets); i++ {
+					all = append(all, widgets[i])
+				}
+				return all, nil
+			},
+			Fetch: func(_ context.Context, id int) (widget, error) {
+				w, found := widgets[id]
+				if !found {
+					return w, fmt.Errorf("widget %d: %w", id, resweave.ErrNotFound)
+				}
+				return w, nil
+			},
+			Create: func(_ context.Context, w widget) (widget, error) {
+				if w.Name == "" {
+					return w, resweave.ErrInvalid
+				}
+				if w.Name == "tea" {
+					return w, teapotError{}
+				}
+				w.ID = len(widgets) + 1
+				widgets[w.ID] = w
+				return w, nil
+			},
+			Delete: func(_ context.Context, id int) error {
+				if id == 1 {
+					return resweave.ErrConflict
+				}
+				if id > 1000 {
+					return errors.New("boom")
+				}
+				delete(widgets, id)
+				return nil
+			},
+		})
+		Expect(s.AddResource(res)).To(Succeed())
+	})
+
+	It("should encode List and Fetch results as JSON", func() {
+		rec := do(http.MethodGet, "/widgets", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(rec.Body.String()).To(MatchJSON(`[{"id":1,"name":"one"}]`))
+
+		rec = do(http.MethodGet, "/widgets/1", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(MatchJSON(`{"id":1,"name":"one"}`))
+	})
+
+	It("should encode an empty List as an empty array", func() {
+		widgets = map[int]widget{}
+		Expect(do(http.MethodGet, "/widgets", "").Body.String()).To(MatchJSON(`[]`))
+	})
+
+	It("should decode the body for Create and answer with a 201", func() {
+		rec := do(http.MethodPost, "/widgets", `{"name":"two"}`)
+		Expect(rec.Code).To(Equal(http.StatusCreated))
+		Expect(rec.Body.String()).To(MatchJSON(`{"id":2,"name":"two"}`))
+		Expect(widgets).To(HaveKey(2))
+	})
+
+	It("should answer Delete with a 204", func() {
+		widgets[2] = widget{ID: 2}
+		rec := do(http.MethodDelete, "/widgets/2", "")
+		Expect(rec.Code).To(Equal(http.StatusNoContent))
+		Expect(rec.Body.String()).To(BeEmpty())
+		Expect(widgets).ToNot(HaveKey(2))
+	})
+
+	It("should map errors to status codes", func() {
+		Expect(do(http.MethodGet, "/widgets/7", "").Code).To(Equal(http.StatusNotFound))
+		Expect(do(http.MethodPost, "/widgets", `{}`).Code).To(Equal(http.StatusBadRequest))
+		Expect(do(http.MethodPost, "/widgets", `{"name":"tea"}`).Code).To(Equal(http.StatusTeapot))
+		Expect(do(http.MethodDelete, "/widgets/1", "").Code).To(Equal(http.StatusConflict))
+		Expect(do(http.MethodDelete, "/widgets/1001", "").Code).To(Equal(http.StatusInternalServerError))
+	})
+
+	It("should reject malformed bodies, non-JSON bodies and unparsable IDs", func() {
+		Expect(do(http.MethodPost, "/widgets", `{"name":`).Code).To(Equal(http.StatusBadRequest))
+		Expect(do(http.MethodGet, "/widgets/99999999999999999999", "").Code).To(Equal(http.StatusBadRequest))
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/widgets", strings.NewReader(`name=two`))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		s.ServeHTTP(recorder, req)
+		Expect(recorder.Code).To(Equal(http.StatusUnsupportedMediaType))
+	})
+
+	It("should not serve handlers which are not set", func() {
+		rec := do(http.MethodPut, "/widgets/1", `{"name":"uno"}`)
+		Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(rec.Header().Get("Allow")).To(Equal("DELETE, GET, HEAD, OPTIONS, POST"))
+	})
+
+	It("should only match IDs made of digits", func() {
+		Expect(do(http.MethodGet, "/widgets/abc1", "").Code).To(Equal(http.StatusNotFound))
+		Expect(do(http.MethodGet, "/widgets/-1", "").Code).To(Equal(http.StatusNotFound))
+		Expect(do(http.MethodGet, "/widgets/1", "").Code).To(Equal(http.StatusOK))
+	})
+
+	It("should not pass PATCH requests to Update without Fetch", func() {
+		updated := false
+		gadgets := resweave.NewTypedAPI("gadgets", resweave.TypedHandlers[widget, int]{
+			Update: func(_ context.Context, _ int, w widget) (widget, error) {
+				updated = true
+				return w, nil
+			},
+		})
+		Expect(s.AddResource(gadgets)).To(Succeed())
+		rec := do(http.MethodPatch, "/gadgets/1", `{"name":"uno"}`)
+		Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(rec.Header().Get("Allow")).To(Equal("OPTIONS, PUT"))
+		Expect(updated).To(BeFalse())
+
+		rec = do(http.MethodOptions, "/gadgets/1", "")
+		Expect(rec.Code).To(Equal(http.StatusNoContent))
+		Expect(rec.Header().Get("Allow")).To(Equal("OPTIONS, PUT"))
+		Expect(rec.Header().Get("Accept-Patch")).To(BeEmpty())
+		for _, r := range s.Routes() {
+			if r.Path == "/gadgets/{gadgets_id}" {
+				Expect(r.Methods).To(Equal([]string{http.MethodOptions, http.MethodPut}))
+			}
+		}
+		doc := resweave.NewOpenAPIDocument(resweave.OpenAPIInfo{}, s.Routes())
+		Expect(doc.Paths["/gadgets/{gadgets_id}"]).To(SatisfyAll(HaveKey("put"), Not(HaveKey("patch"))))
+
+		Expect(do(http.MethodPut, "/gadgets/1", `{"name":"uno"}`).Code).To(Equal(http.StatusOK))
+		Expect(updated).To(BeTrue())
+	})
+
+	It("should parse string IDs", func() {
+		var got string
+		tags := resweave.NewTypedAPI("tags", resweave.TypedHandlers[widget, string]{
+			Fetch: func(_ context.Context, id string) (widget, error) {
+				got = id
+				return widget{Name: id}, nil
+			},
+			Update: func(_ context.Context, id string, w widget) (widget, error) {
+				w.Name = id + ":" + w.Name
+				return w, nil
+			},
+		})
+		Expect(s.AddResource(tags)).To(Succeed())
+		Expect(do(http.MethodGet, "/tags/go-lang", "").Code).To(Equal(http.StatusOK))
+		Expect(got).To(Equal("go-lang"))
+
+		rec := do(http.MethodPatch, "/tags/go", `{"name":"lang"}`)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(MatchJSON(`{"id":0,"name":"go:lang"}`))
+		Expect(do(http.MethodPut, "/tags", `{"name":"lang"}`).Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})